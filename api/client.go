@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client calls the operator endpoints of a running deathnode
+type Client struct {
+	URL        string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for the deathnode listening on url
+func NewClient(url, token string) *Client {
+
+	return &Client{
+		URL:        strings.TrimSuffix(url, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Do requests an action (mark, unmark, force, quarantine or unquarantine) on an instance
+func (c *Client) Do(action, instanceID string) error {
+
+	if err := c.post(instancesPath + instanceID + "/" + action); err != nil {
+		return fmt.Errorf("%s %s failed %s", action, instanceID, err)
+	}
+	return nil
+}
+
+// SetPaused pauses or resumes the removals on all autoscaling groups
+func (c *Client) SetPaused(paused bool) error {
+
+	action := ActionResume
+	if paused {
+		action = ActionPause
+	}
+	if err := c.post("/" + action); err != nil {
+		return fmt.Errorf("%s failed %s", action, err)
+	}
+	return nil
+}
+
+func (c *Client) post(path string) error {
+
+	request, err := http.NewRequest("POST", c.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.Token)
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var body map[string]string
+		json.NewDecoder(response.Body).Decode(&body)
+		return fmt.Errorf("(%d): %s", response.StatusCode, body["error"])
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyPath   = "/ready"
+)
+
+// livenessResponse is the body returned by /healthz
+type livenessResponse struct {
+	Alive            bool      `json:"alive"`
+	LastRunCompleted time.Time `json:"lastRunCompleted,omitempty"`
+	Since            string    `json:"since"`
+}
+
+// readinessResponse is the body returned by /ready
+type readinessResponse struct {
+	Ready      bool              `json:"ready"`
+	Components map[string]string `json:"components"`
+}
+
+// handleHealthz fails if no run has completed within the liveness timeout. Until the first run
+// completes, the timeout counts from the server start
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+
+	lastRunCompleted := s.watcher.LastRunCompleted()
+	reference := lastRunCompleted
+	if reference.IsZero() {
+		reference = s.started
+	}
+
+	since := s.clock.Since(reference)
+	response := livenessResponse{
+		Alive:            s.livenessTimeout <= 0 || since <= s.livenessTimeout,
+		LastRunCompleted: lastRunCompleted,
+		Since:            since.String(),
+	}
+
+	code := http.StatusOK
+	if !response.Alive {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, response)
+}
+
+// handleReady fails unless the last refresh of AWS, Mesos and, if configured, Aurora succeeded
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+
+	components, ready := s.watcher.Readiness()
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, readinessResponse{Ready: ready, Components: components})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alanbover/deathnode/deathnode"
+	"github.com/benbjohnson/clock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealth(t *testing.T) {
+
+	Convey("When checking the health of deathnode", t, func() {
+		watcher := newWatcher()
+		server := NewServer(watcher, time.Minute)
+		clockMock := clock.NewMock()
+		server.clock = clockMock
+		server.started = clockMock.Now()
+
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+
+		Convey("before the first run", func() {
+			Convey("it should be alive within the liveness timeout since start", func() {
+				So(get("/healthz").Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("it should not be alive once the liveness timeout expires", func() {
+				clockMock.Add(2 * time.Minute)
+				So(get("/healthz").Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+			Convey("it should not be ready", func() {
+				So(get("/ready").Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+		Convey("after a successful run", func() {
+			watcher.Run()
+
+			Convey("it should be ready, reporting every refreshed component", func() {
+				recorder := get("/ready")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+
+				var response readinessResponse
+				So(json.Unmarshal(recorder.Body.Bytes(), &response), ShouldBeNil)
+				So(response.Components, ShouldResemble, map[string]string{
+					deathnode.ComponentAWS:   "ok",
+					deathnode.ComponentMesos: "ok",
+				})
+			})
+			Convey("liveness should count from the last completed run", func() {
+				So(watcher.LastRunCompleted().IsZero(), ShouldBeFalse)
+				server.clock = clock.New()
+				So(get("/healthz").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
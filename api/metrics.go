@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/alanbover/deathnode/deathnode"
+	"github.com/alanbover/deathnode/metrics"
+	"github.com/alanbover/deathnode/monitor"
+)
+
+const metricsPath = "/metrics"
+
+// handleMetrics returns the deathnode metrics in the Prometheus text format. Gauges are computed from
+// the watcher status on every request
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	setGauges(s.watcher.Status())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.DefaultRegistry.Write(w)
+}
+
+func setGauges(status deathnode.Status) {
+
+	for _, gauge := range []*metrics.Gauge{metrics.DesiredInstances, metrics.Instances, metrics.InstancesMarked,
+		metrics.InstancesDraining, metrics.InstancesAwaitingTerminatingWait, metrics.Paused} {
+		gauge.Reset()
+	}
+
+	metrics.AutoscalingGroups.Set(float64(len(status.AutoscalingGroups)))
+	metrics.Paused.Set(boolToFloat(status.Paused), "")
+	metrics.OrphanInstances.Set(float64(len(status.Orphans)))
+	for _, autoscalingGroup := range status.AutoscalingGroups {
+		marked, draining, awaiting := 0, 0, 0
+		for _, instance := range autoscalingGroup.Instances {
+			if instance.Drain == nil {
+				continue
+			}
+			marked++
+			if instance.LifecycleState == monitor.LifecycleStateTerminatingWait {
+				draining++
+			} else {
+				awaiting++
+			}
+		}
+
+		metrics.DesiredInstances.Set(float64(autoscalingGroup.DesiredCapacity), autoscalingGroup.Name)
+		metrics.Instances.Set(float64(len(autoscalingGroup.Instances)), autoscalingGroup.Name)
+		metrics.InstancesMarked.Set(float64(marked), autoscalingGroup.Name)
+		metrics.InstancesDraining.Set(float64(draining), autoscalingGroup.Name)
+		metrics.InstancesAwaitingTerminatingWait.Set(float64(awaiting), autoscalingGroup.Name)
+		metrics.Paused.Set(boolToFloat(autoscalingGroup.Paused), autoscalingGroup.Name)
+	}
+}
+
+func boolToFloat(value bool) float64 {
+
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/alanbover/deathnode/deathnode"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	instancesPath = "/instances/"
+	pausePath     = "/pause"
+	resumePath    = "/resume"
+
+	// ActionMark marks an instance to be removed
+	ActionMark = "mark"
+	// ActionUnmark cancels the removal of an instance
+	ActionUnmark = "unmark"
+	// ActionForce destroys an instance without waiting for its tasks to finish
+	ActionForce = "force"
+	// ActionQuarantine drains an instance and moves it to Standby
+	ActionQuarantine = "quarantine"
+	// ActionUnquarantine moves a quarantined instance out of Standby and ends its maintenance
+	ActionUnquarantine = "unquarantine"
+	// ActionPause pauses the removals on all autoscaling groups
+	ActionPause = "pause"
+	// ActionResume resumes the removals paused by ActionPause
+	ActionResume = "resume"
+)
+
+// operatorResponse is the body returned by the operator endpoints on success
+type operatorResponse struct {
+	InstanceID string `json:"instanceId,omitempty"`
+	Action     string `json:"action"`
+}
+
+// EnableOperatorAPI serves the operator endpoints, POST /instances/<instanceId>/(mark|unmark|force|quarantine|unquarantine),
+// POST /pause and POST /resume, authenticated with a bearer token
+func (s *Server) EnableOperatorAPI(token string) {
+
+	s.operatorToken = token
+	s.mux.HandleFunc(instancesPath, s.handleOperator)
+	s.mux.HandleFunc(pausePath, s.handlePause)
+	s.mux.HandleFunc(resumePath, s.handlePause)
+}
+
+func (s *Server) handleOperator(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkOperatorRequest(w, r) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, instancesPath), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "Expected /instances/<instanceId>/<action>")
+		return
+	}
+	instanceID, action := parts[0], parts[1]
+
+	var err error
+	switch action {
+	case ActionMark:
+		err = s.watcher.MarkInstance(instanceID)
+	case ActionUnmark:
+		err = s.watcher.UnmarkInstance(instanceID)
+	case ActionForce:
+		err = s.watcher.ForceDestroyInstance(instanceID)
+	case ActionQuarantine:
+		err = s.watcher.QuarantineInstance(instanceID)
+	case ActionUnquarantine:
+		err = s.watcher.UnquarantineInstance(instanceID)
+	default:
+		writeError(w, http.StatusNotFound, "Unknown action "+action)
+		return
+	}
+
+	if err != nil {
+		log.Warnf("Operator request %s on instance %s failed: %s", action, instanceID, err)
+		writeError(w, operatorErrorCode(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, operatorResponse{InstanceID: instanceID, Action: action})
+}
+
+// handlePause pauses or resumes the removals on all autoscaling groups, depending on the path
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+
+	if !s.checkOperatorRequest(w, r) {
+		return
+	}
+
+	action := strings.TrimPrefix(r.URL.Path, "/")
+	if action == ActionPause {
+		s.watcher.Pause("api")
+	} else {
+		s.watcher.Resume("api")
+	}
+	writeJSON(w, http.StatusOK, operatorResponse{Action: action})
+}
+
+// checkOperatorRequest writes an error and returns false unless the request is an authorized POST
+func (s *Server) checkOperatorRequest(w http.ResponseWriter, r *http.Request) bool {
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return false
+	}
+
+	if !s.isAuthorized(r) {
+		log.Warnf("Unauthorized operator request %s from %s", r.URL.Path, r.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+func (s *Server) isAuthorized(r *http.Request) bool {
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.operatorToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.operatorToken)) == 1
+}
+
+func operatorErrorCode(err error) int {
+
+	operatorErr, ok := err.(*deathnode.OperatorError)
+	switch {
+	case !ok:
+		return http.StatusInternalServerError
+	case operatorErr.NotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusConflict
+	}
+}
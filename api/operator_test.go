@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOperatorAPI(t *testing.T) {
+
+	Convey("When calling the operator API", t, func() {
+		watcher := newWatcher()
+		watcher.Run()
+		server := NewServer(watcher, 0)
+
+		post := func(path, token string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("POST", path, nil)
+			if token != "" {
+				request.Header.Set("Authorization", "Bearer "+token)
+			}
+			server.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("it should not be served unless enabled", func() {
+			So(post("/instances/i-446a73cf/mark", "secret").Code, ShouldEqual, http.StatusNotFound)
+		})
+		Convey("once enabled", func() {
+			server.EnableOperatorAPI("secret")
+
+			Convey("requests without a valid token should be rejected", func() {
+				So(post("/instances/i-446a73cf/mark", "").Code, ShouldEqual, http.StatusUnauthorized)
+				So(post("/instances/i-446a73cf/mark", "wrong").Code, ShouldEqual, http.StatusUnauthorized)
+			})
+			Convey("authorized requests should be applied", func() {
+				So(post("/instances/i-446a73cf/mark", "secret").Code, ShouldEqual, http.StatusOK)
+				So(post("/instances/i-446a73cf/mark", "secret").Code, ShouldEqual, http.StatusConflict)
+				So(post("/instances/i-unknown/mark", "secret").Code, ShouldEqual, http.StatusNotFound)
+				So(post("/instances/i-446a73cf/reboot", "secret").Code, ShouldEqual, http.StatusNotFound)
+			})
+			Convey("the client should call the operator API", func() {
+				httpServer := httptest.NewServer(server)
+				defer httpServer.Close()
+
+				So(NewClient(httpServer.URL, "secret").Do(ActionMark, "i-446a73cf"), ShouldBeNil)
+				So(NewClient(httpServer.URL, "wrong").Do(ActionUnmark, "i-446a73cf"), ShouldNotBeNil)
+				So(NewClient(httpServer.URL, "secret").Do(ActionUnmark, "i-446a73cf"), ShouldBeNil)
+			})
+			Convey("pausing should freeze the removals until resumed", func() {
+				httpServer := httptest.NewServer(server)
+				defer httpServer.Close()
+
+				So(post("/pause", "").Code, ShouldEqual, http.StatusUnauthorized)
+				So(NewClient(httpServer.URL, "secret").SetPaused(true), ShouldBeNil)
+				So(watcher.Status().Paused, ShouldBeTrue)
+				So(post("/instances/i-446a73cf/mark", "secret").Code, ShouldEqual, http.StatusConflict)
+				So(NewClient(httpServer.URL, "secret").SetPaused(false), ShouldBeNil)
+				So(watcher.Status().Paused, ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,102 @@
+package api
+
+// Embedded HTTP server exposing deathnode state as JSON
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alanbover/deathnode/deathnode"
+	"github.com/benbjohnson/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+const statusPath = "/status"
+
+// Server serves the deathnode HTTP endpoints
+type Server struct {
+	watcher         *deathnode.Watcher
+	mux             *http.ServeMux
+	livenessTimeout time.Duration
+	operatorToken   string
+	started         time.Time
+	clock           clock.Clock
+}
+
+// NewServer returns a Server exposing the state of the watcher. /healthz fails when no run has completed
+// within livenessTimeout (0 disables it)
+func NewServer(watcher *deathnode.Watcher, livenessTimeout time.Duration) *Server {
+
+	serverClock := clock.New()
+	server := &Server{
+		watcher:         watcher,
+		mux:             http.NewServeMux(),
+		livenessTimeout: livenessTimeout,
+		started:         serverClock.Now(),
+		clock:           serverClock,
+	}
+
+	server.mux.HandleFunc(statusPath, server.handleStatus)
+	server.mux.HandleFunc(statusPath+"/", server.handleAutoscalingGroupStatus)
+	server.mux.HandleFunc(metricsPath, server.handleMetrics)
+	server.mux.HandleFunc(healthzPath, server.handleHealthz)
+	server.mux.HandleFunc(readyPath, server.handleReady)
+	return server
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe starts serving the endpoints on the given address. It only returns on error
+func (s *Server) ListenAndServe(address string) error {
+
+	log.Infof("Listening for HTTP requests on %s", address)
+	return http.ListenAndServe(address, s)
+}
+
+// handleStatus returns all the monitored autoscaling groups, with their instances
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.watcher.Status())
+}
+
+// handleAutoscalingGroupStatus returns a single autoscaling group, given its name: /status/<name>
+func (s *Server) handleAutoscalingGroupStatus(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, statusPath+"/")
+	for _, autoscalingGroup := range s.watcher.Status().AutoscalingGroups {
+		if autoscalingGroup.Name == name {
+			writeJSON(w, http.StatusOK, autoscalingGroup)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "Autoscaling group "+name+" not found")
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Warnf("Unable to write HTTP response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/deathnode"
+	"github.com/alanbover/deathnode/mesos"
+	"github.com/benbjohnson/clock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newWatcher() *deathnode.Watcher {
+
+	ctx := &context.ApplicationContext{
+		Clock: clock.New(),
+		AwsConn: &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":   {"node1", "node2", "node3"},
+				"DescribeInstancesByTag": {"one_undesired_host", "default"},
+				"DescribeAGByName":       {"one_undesired_host"},
+			},
+		},
+		MesosConn: &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"notasks"},
+			},
+		},
+		Conf: context.ApplicationConf{
+			DeathNodeMark:            "DEATH_NODE_MARK",
+			AutoscalingGroupPrefixes: []string{"some-Autoscaling-Group"},
+			ProtectedFrameworks:      []string{"frameworkName1"},
+			ConstraintsType:          []string{"noConstraint"},
+			RecommenderType:          "smallestInstanceId",
+		},
+	}
+
+	return deathnode.NewWatcher(ctx)
+}
+
+func TestStatus(t *testing.T) {
+
+	Convey("When requesting the status after a run", t, func() {
+		watcher := newWatcher()
+		watcher.Run()
+		server := NewServer(watcher, 0)
+
+		Convey("it should list the autoscaling groups with their instances", func() {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			var status deathnode.Status
+			So(json.Unmarshal(recorder.Body.Bytes(), &status), ShouldBeNil)
+			So(status.AutoscalingGroups, ShouldHaveLength, 1)
+
+			autoscalingGroup := status.AutoscalingGroups[0]
+			So(autoscalingGroup.Name, ShouldEqual, "some-Autoscaling-Group")
+			So(autoscalingGroup.DesiredCapacity, ShouldEqual, 2)
+			So(autoscalingGroup.Instances, ShouldHaveLength, 3)
+
+			marked := []deathnode.InstanceStatus{}
+			for _, instance := range autoscalingGroup.Instances {
+				if instance.Drain != nil {
+					marked = append(marked, instance)
+				}
+			}
+			So(marked, ShouldHaveLength, 1)
+			So(marked[0].TagRemovalTimestamp, ShouldNotEqual, 0)
+			So(marked[0].Drain.PendingReasons, ShouldResemble, []string{deathnode.PendingNotTerminatingWait})
+		})
+		Convey("it should return a single autoscaling group by name", func() {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/status/some-Autoscaling-Group", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			var autoscalingGroup deathnode.AutoscalingGroupStatus
+			So(json.Unmarshal(recorder.Body.Bytes(), &autoscalingGroup), ShouldBeNil)
+			So(autoscalingGroup.Name, ShouldEqual, "some-Autoscaling-Group")
+		})
+		Convey("it should return not found for unknown autoscaling groups", func() {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/status/unknown", nil))
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+		})
+		Convey("it should export the metrics in the Prometheus text format", func() {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "deathnode_autoscaling_groups 1\n")
+			So(recorder.Body.String(), ShouldContainSubstring,
+				`deathnode_instances_awaiting_terminating_wait{autoscaling_group="some-Autoscaling-Group"} 1`)
+			So(recorder.Body.String(), ShouldContainSubstring,
+				`deathnode_tags_set_total{autoscaling_group="some-Autoscaling-Group"}`)
+		})
+		Convey("it should only accept GET", func() {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest("POST", "/status", nil))
+			So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package aurora
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// DryRunClient wraps an aurora client, forwarding the read-only calls and logging the mutating ones
+// instead of executing them
+type DryRunClient struct {
+	client ClientInterface
+}
+
+// NewDryRunClient returns a DryRunClient wrapping an aurora client
+func NewDryRunClient(client ClientInterface) *DryRunClient {
+	return &DryRunClient{client: client}
+}
+
+// GetMaintenance returns the Aurora maintenance info
+func (c *DryRunClient) GetMaintenance() (*MaintenanceResponse, error) {
+	return c.client.GetMaintenance()
+}
+
+// StartMaintenance logs the hosts to be put in maintenance without calling Aurora
+func (c *DryRunClient) StartMaintenance(hosts map[string]string) error {
+
+	log.WithField("hosts", hosts).Info("Dry-run: would start maintenance")
+	return nil
+}
+
+// EndMaintenance logs the hosts to be taken out of maintenance without calling Aurora
+func (c *DryRunClient) EndMaintenance(hosts map[string]string) error {
+
+	log.WithField("hosts", hosts).Info("Dry-run: would end maintenance")
+	return nil
+}
+
+// DrainHosts logs the hosts to be drained without calling Aurora
+func (c *DryRunClient) DrainHosts(hosts map[string]string) error {
+
+	log.WithField("hosts", hosts).Info("Dry-run: would drain hosts")
+	return nil
+}
@@ -0,0 +1,52 @@
+package aurora
+
+import (
+	"time"
+
+	"github.com/alanbover/deathnode/metrics"
+)
+
+const metricsClientName = "aurora"
+
+// InstrumentedClient wraps an aurora client, recording the number of calls, errors and latency of
+// every method
+type InstrumentedClient struct {
+	client ClientInterface
+}
+
+// NewInstrumentedClient returns an InstrumentedClient wrapping an aurora client
+func NewInstrumentedClient(client ClientInterface) *InstrumentedClient {
+	return &InstrumentedClient{client: client}
+}
+
+// GetMaintenance returns the Aurora maintenance info
+func (c *InstrumentedClient) GetMaintenance() (response *MaintenanceResponse, err error) {
+
+	defer observe("GetMaintenance", time.Now(), &err)
+	return c.client.GetMaintenance()
+}
+
+// StartMaintenance puts the hosts in maintenance
+func (c *InstrumentedClient) StartMaintenance(hosts map[string]string) (err error) {
+
+	defer observe("StartMaintenance", time.Now(), &err)
+	return c.client.StartMaintenance(hosts)
+}
+
+// EndMaintenance takes the hosts out of maintenance
+func (c *InstrumentedClient) EndMaintenance(hosts map[string]string) (err error) {
+
+	defer observe("EndMaintenance", time.Now(), &err)
+	return c.client.EndMaintenance(hosts)
+}
+
+// DrainHosts drains the hosts
+func (c *InstrumentedClient) DrainHosts(hosts map[string]string) (err error) {
+
+	defer observe("DrainHosts", time.Now(), &err)
+	return c.client.DrainHosts(hosts)
+}
+
+func observe(method string, start time.Time, err *error) {
+	metrics.ObserveClientRequest(metricsClientName, method, start, *err)
+}
@@ -11,9 +11,10 @@ import (
 )
 
 const (
-	lifecycleHookName                   = "DEATHNODE"
-	continueString                      = "CONTINUE"
-	lifecycleTransitionTerminationState = "autoscaling:EC2_INSTANCE_TERMINATING"
+	// maxAutoscalingGroupNames is the maximum number of names accepted by DescribeAutoScalingGroups
+	maxAutoscalingGroupNames = 50
+	// maxInstanceIDsFilter is the number of instance IDs sent on each DescribeInstances call
+	maxInstanceIDsFilter = 200
 )
 
 // Client holds the AWS SDK objects for call AWS API
@@ -25,19 +26,36 @@ type Client struct {
 // ClientInterface implements a client with all required operations against AWS API
 type ClientInterface interface {
 	DescribeInstanceByID(instanceID string) (*ec2.Instance, error)
+	DescribeInstancesByIDs(instanceIDs []string) ([]*ec2.Instance, error)
 	DescribeInstancesByTag(tagKey string) ([]*ec2.Instance, error)
 	DescribeAGsByPrefix(autoscalingGroupName string) ([]*autoscaling.Group, error)
+	DescribeAGsByTags(tags map[string]string) ([]*autoscaling.Group, error)
+	DescribeAGByName(autoscalingGroupName string) (*autoscaling.Group, error)
 	RemoveASGInstanceProtection(autoscalingGroupName, instanceID *string) error
 	SetASGInstanceProtection(autoscalingGroupName *string, instanceIDs []*string) error
 	SetInstanceTag(key, value, instanceID string) error
-	HasLifeCycleHook(autoscalingGroupName string) (bool, error)
-	PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error
-	CompleteLifecycleAction(autoscalingGroupName, instanceID *string) error
+	DeleteInstanceTag(key, instanceID string) error
+	TerminateASGInstance(instanceID *string, shouldDecrementDesiredCapacity bool) error
+	DetachInstance(autoscalingGroupName, instanceID *string, shouldDecrementDesiredCapacity bool) error
+	TerminateInstance(instanceID *string) error
+	EnterStandby(autoscalingGroupName, instanceID *string, shouldDecrementDesiredCapacity bool) error
+	ExitStandby(autoscalingGroupName, instanceID *string) error
+	DescribeLifeCycleHook(autoscalingGroupName, lifecycleHookName string) (*autoscaling.LifecycleHook, error)
+	PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64, defaultResult string) error
+	PutLaunchLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error
+	DeleteLaunchLifeCycleHook(autoscalingGroupName string) error
+	CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID *string, result string) error
+	CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken *string, result string) error
 	RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID *string) error
 }
 
 // NewClient returns a new aws.client
 func NewClient(accessKey, secretKey, region, iamRole, iamSession string) (*Client, error) {
+	return NewClientWithExternalID(accessKey, secretKey, region, iamRole, iamSession, "")
+}
+
+// NewClientWithExternalID returns a new aws.client, passing an external ID when assuming iamRole
+func NewClientWithExternalID(accessKey, secretKey, region, iamRole, iamSession, externalID string) (*Client, error) {
 
 	session, err := newAwsSession(&sessionParameters{
 		accessKey:  accessKey,
@@ -45,6 +63,7 @@ func NewClient(accessKey, secretKey, region, iamRole, iamSession string) (*Clien
 		region:     region,
 		iamRole:    iamRole,
 		iamSession: iamSession,
+		externalID: externalID,
 	})
 
 	if err != nil {
@@ -63,29 +82,32 @@ func (c *Client) RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID
 	recordLifeCycleActionHeartbeatInput := &autoscaling.RecordLifecycleActionHeartbeatInput{
 		AutoScalingGroupName: autoscalingGroupName,
 		InstanceId:           instanceID,
-		LifecycleHookName:    aws.String(lifecycleHookName),
+		LifecycleHookName:    aws.String(LifecycleHookName),
 	}
 
 	_, err := c.autoscaling.RecordLifecycleActionHeartbeat(recordLifeCycleActionHeartbeatInput)
 	return err
 }
 
-// CompleteLifecycleAction completes a lifecycle event for an instance pending to be deleted
-func (c *Client) CompleteLifecycleAction(autoscalingGroupName, instanceID *string) error {
+// CompleteLifecycleAction completes a lifecycle event for an instance pending to be deleted with the result
+// (CONTINUE or ABANDON). The lifecycle action token is optional
+func (c *Client) CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken *string,
+	result string) error {
 
 	completeLifecycleActionInput := &autoscaling.CompleteLifecycleActionInput{
 		AutoScalingGroupName:  autoscalingGroupName,
 		InstanceId:            instanceID,
-		LifecycleActionResult: aws.String(continueString),
-		LifecycleHookName:     aws.String(lifecycleHookName),
+		LifecycleActionResult: aws.String(result),
+		LifecycleActionToken:  lifecycleActionToken,
+		LifecycleHookName:     aws.String(LifecycleHookName),
 	}
 
 	_, err := c.autoscaling.CompleteLifecycleAction(completeLifecycleActionInput)
 	return err
 }
 
-// HasLifeCycleHook checks if deathnode lifecyclehook is enabled for an autoscalingGroup
-func (c *Client) HasLifeCycleHook(autoscalingGroupName string) (bool, error) {
+// DescribeLifeCycleHook returns a lifecycle hook of an autoscalingGroup, or nil if it doesn't exist
+func (c *Client) DescribeLifeCycleHook(autoscalingGroupName, lifecycleHookName string) (*autoscaling.LifecycleHook, error) {
 
 	describeLifecycleHooksInput := &autoscaling.DescribeLifecycleHooksInput{
 		AutoScalingGroupName: aws.String(autoscalingGroupName),
@@ -94,27 +116,73 @@ func (c *Client) HasLifeCycleHook(autoscalingGroupName string) (bool, error) {
 
 	describeLifecycleHooksOutput, err := c.autoscaling.DescribeLifecycleHooks(describeLifecycleHooksInput)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return len(describeLifecycleHooksOutput.LifecycleHooks) != 0, nil
+	if len(describeLifecycleHooksOutput.LifecycleHooks) == 0 {
+		return nil, nil
+	}
+	return describeLifecycleHooksOutput.LifecycleHooks[0], nil
 }
 
-// PutLifeCycleHook adds an INSTANCE_TERMINATING lifecycle hook to an autoscalingGroup
-func (c *Client) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error {
+// PutLifeCycleHook adds an INSTANCE_TERMINATING lifecycle hook to an autoscalingGroup, applying the
+// defaultResult (CONTINUE or ABANDON) when heartbeatTimeout expires
+func (c *Client) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64, defaultResult string) error {
 
 	putLifecycleHookInput := &autoscaling.PutLifecycleHookInput{
 		AutoScalingGroupName: aws.String(autoscalingGroupName),
-		DefaultResult:        aws.String(continueString),
+		DefaultResult:        aws.String(defaultResult),
 		HeartbeatTimeout:     heartbeatTimeout,
-		LifecycleHookName:    aws.String(lifecycleHookName),
-		LifecycleTransition:  aws.String(lifecycleTransitionTerminationState),
+		LifecycleHookName:    aws.String(LifecycleHookName),
+		LifecycleTransition:  aws.String(LifecycleTransitionTerminating),
 	}
 
 	_, err := c.autoscaling.PutLifecycleHook(putLifecycleHookInput)
 	return err
 }
 
+// PutLaunchLifeCycleHook adds an INSTANCE_LAUNCHING lifecycle hook to an autoscalingGroup. New instances
+// are abandoned if the lifecycle action is not completed before heartbeatTimeout
+func (c *Client) PutLaunchLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error {
+
+	putLifecycleHookInput := &autoscaling.PutLifecycleHookInput{
+		AutoScalingGroupName: aws.String(autoscalingGroupName),
+		DefaultResult:        aws.String(LifecycleActionAbandon),
+		HeartbeatTimeout:     heartbeatTimeout,
+		LifecycleHookName:    aws.String(LaunchLifecycleHookName),
+		LifecycleTransition:  aws.String(LifecycleTransitionLaunching),
+	}
+
+	_, err := c.autoscaling.PutLifecycleHook(putLifecycleHookInput)
+	return err
+}
+
+// DeleteLaunchLifeCycleHook removes the INSTANCE_LAUNCHING lifecycle hook from an autoscalingGroup
+func (c *Client) DeleteLaunchLifeCycleHook(autoscalingGroupName string) error {
+
+	deleteLifecycleHookInput := &autoscaling.DeleteLifecycleHookInput{
+		AutoScalingGroupName: aws.String(autoscalingGroupName),
+		LifecycleHookName:    aws.String(LaunchLifecycleHookName),
+	}
+
+	_, err := c.autoscaling.DeleteLifecycleHook(deleteLifecycleHookInput)
+	return err
+}
+
+// CompleteLaunchLifecycleAction completes the launch lifecycle event of an instance with the result
+func (c *Client) CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID *string, result string) error {
+
+	completeLifecycleActionInput := &autoscaling.CompleteLifecycleActionInput{
+		AutoScalingGroupName:  autoscalingGroupName,
+		InstanceId:            instanceID,
+		LifecycleActionResult: aws.String(result),
+		LifecycleHookName:     aws.String(LaunchLifecycleHookName),
+	}
+
+	_, err := c.autoscaling.CompleteLifecycleAction(completeLifecycleActionInput)
+	return err
+}
+
 // DescribeAGsByPrefix returns all autoscaling groups that matches a certain prefix
 func (c *Client) DescribeAGsByPrefix(autoscalingGroupPrefix string) ([]*autoscaling.Group, error) {
 
@@ -140,6 +208,92 @@ func (c *Client) DescribeAGsByPrefix(autoscalingGroupPrefix string) ([]*autoscal
 	return autoscalingGroupList, nil
 }
 
+// DescribeAGByName returns the autoscaling group with the name
+func (c *Client) DescribeAGByName(autoscalingGroupName string) (*autoscaling.Group, error) {
+
+	response, err := c.autoscaling.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: aws.StringSlice([]string{autoscalingGroupName}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.AutoScalingGroups) < 1 {
+		return nil, fmt.Errorf("No autoscaling group found with name %v", autoscalingGroupName)
+	}
+
+	return response.AutoScalingGroups[0], nil
+}
+
+// DescribeAGsByTags returns all autoscaling groups that have all the tags, with the same values
+func (c *Client) DescribeAGsByTags(tags map[string]string) ([]*autoscaling.Group, error) {
+
+	// Filters with different names are ANDed, so query every tag on its own and intersect the results
+	var autoscalingGroupNames map[string]bool
+	for key, value := range tags {
+		names, err := c.describeAGNamesByTag(key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if autoscalingGroupNames != nil {
+			for name := range autoscalingGroupNames {
+				if !names[name] {
+					delete(autoscalingGroupNames, name)
+				}
+			}
+		} else {
+			autoscalingGroupNames = names
+		}
+	}
+
+	names := []*string{}
+	for name := range autoscalingGroupNames {
+		names = append(names, aws.String(name))
+	}
+
+	autoscalingGroupList := []*autoscaling.Group{}
+	for start := 0; start < len(names); start += maxAutoscalingGroupNames {
+		end := start + maxAutoscalingGroupNames
+		if end > len(names) {
+			end = len(names)
+		}
+
+		err := c.autoscaling.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: names[start:end],
+		}, func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			autoscalingGroupList = append(autoscalingGroupList, page.AutoScalingGroups...)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return autoscalingGroupList, nil
+}
+
+// describeAGNamesByTag returns the names of the autoscaling groups with a tag set to a value
+func (c *Client) describeAGNamesByTag(key, value string) (map[string]bool, error) {
+
+	names := map[string]bool{}
+	err := c.autoscaling.DescribeTagsPages(&autoscaling.DescribeTagsInput{
+		Filters: []*autoscaling.Filter{
+			{Name: aws.String("key"), Values: []*string{aws.String(key)}},
+			{Name: aws.String("value"), Values: []*string{aws.String(value)}},
+		},
+	}, func(page *autoscaling.DescribeTagsOutput, lastPage bool) bool {
+		for _, tag := range page.Tags {
+			if *tag.Key == key && *tag.Value == value {
+				names[*tag.ResourceId] = true
+			}
+		}
+		return true
+	})
+
+	return names, err
+}
+
 func (c *Client) describeAGByNameWithToken(nextToken *string) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
 
 	filter := &autoscaling.DescribeAutoScalingGroupsInput{
@@ -188,7 +342,40 @@ func (c *Client) DescribeInstanceByID(instanceID string) (*ec2.Instance, error)
 	return response.Reservations[0].Instances[0], nil
 }
 
-// DescribeInstancesByTag return all instances with a certain tag set
+// DescribeInstancesByIDs returns the instances that match the instanceIDs, paging through the results in
+// batches of maxInstanceIDsFilter IDs. Unknown instanceIDs are not returned, instead of failing the call
+func (c *Client) DescribeInstancesByIDs(instanceIDs []string) ([]*ec2.Instance, error) {
+
+	instances := []*ec2.Instance{}
+
+	for start := 0; start < len(instanceIDs); start += maxInstanceIDsFilter {
+		end := start + maxInstanceIDsFilter
+		if end > len(instanceIDs) {
+			end = len(instanceIDs)
+		}
+
+		filter := &ec2.DescribeInstancesInput{
+			Filters: []*ec2.Filter{{
+				Name:   aws.String("instance-id"),
+				Values: aws.StringSlice(instanceIDs[start:end]),
+			}},
+		}
+
+		err := c.ec2.DescribeInstancesPages(filter, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, reservation := range page.Reservations {
+				instances = append(instances, reservation.Instances...)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return instances, nil
+}
+
+// DescribeInstancesByTag return all running instances with a certain tag set, paging through the results
 func (c *Client) DescribeInstancesByTag(tagKey string) ([]*ec2.Instance, error) {
 
 	instances := []*ec2.Instance{}
@@ -206,22 +393,16 @@ func (c *Client) DescribeInstancesByTag(tagKey string) ([]*ec2.Instance, error)
 		},
 	}
 
-	response, err := c.ec2.DescribeInstances(filter)
-
+	err := c.ec2.DescribeInstancesPages(filter, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(response.Reservations) == 0 {
-		return []*ec2.Instance{}, nil
-	}
-
-	for _, reservation := range response.Reservations {
-		for _, instance := range reservation.Instances {
-			instances = append(instances, instance)
-		}
-	}
-
 	return instances, nil
 }
 
@@ -282,3 +463,74 @@ func (c *Client) SetInstanceTag(key, value, instanceID string) error {
 
 	return err
 }
+
+// DeleteInstanceTag removes the tag with key from an AWS instance
+func (c *Client) DeleteInstanceTag(key, instanceID string) error {
+
+	_, err := c.ec2.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(instanceID)},
+		Tags:      []*ec2.Tag{{Key: aws.String(key)}},
+	})
+
+	return err
+}
+
+// TerminateASGInstance terminates an instance through its autoscaling group, so lifecycle hooks apply.
+// If shouldDecrementDesiredCapacity is false, the autoscaling group launches a replacement
+func (c *Client) TerminateASGInstance(instanceID *string, shouldDecrementDesiredCapacity bool) error {
+
+	_, err := c.autoscaling.TerminateInstanceInAutoScalingGroup(&autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     instanceID,
+		ShouldDecrementDesiredCapacity: aws.Bool(shouldDecrementDesiredCapacity),
+	})
+
+	return err
+}
+
+// DetachInstance removes an instance from its autoscaling group without terminating it, so no lifecycle
+// hook applies. If shouldDecrementDesiredCapacity is false, the autoscaling group launches a replacement
+func (c *Client) DetachInstance(autoscalingGroupName, instanceID *string, shouldDecrementDesiredCapacity bool) error {
+
+	_, err := c.autoscaling.DetachInstances(&autoscaling.DetachInstancesInput{
+		AutoScalingGroupName:           autoscalingGroupName,
+		InstanceIds:                    []*string{instanceID},
+		ShouldDecrementDesiredCapacity: aws.Bool(shouldDecrementDesiredCapacity),
+	})
+
+	return err
+}
+
+// TerminateInstance terminates an EC2 instance
+func (c *Client) TerminateInstance(instanceID *string) error {
+
+	_, err := c.ec2.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{instanceID},
+	})
+
+	return err
+}
+
+// EnterStandby moves an instance of an autoscaling group to Standby, keeping it running out of service.
+// If shouldDecrementDesiredCapacity is false, the autoscaling group launches a replacement
+func (c *Client) EnterStandby(autoscalingGroupName, instanceID *string, shouldDecrementDesiredCapacity bool) error {
+
+	_, err := c.autoscaling.EnterStandby(&autoscaling.EnterStandbyInput{
+		AutoScalingGroupName:           autoscalingGroupName,
+		InstanceIds:                    []*string{instanceID},
+		ShouldDecrementDesiredCapacity: aws.Bool(shouldDecrementDesiredCapacity),
+	})
+
+	return err
+}
+
+// ExitStandby moves an instance in Standby back to service, incrementing the desired capacity of its
+// autoscaling group
+func (c *Client) ExitStandby(autoscalingGroupName, instanceID *string) error {
+
+	_, err := c.autoscaling.ExitStandby(&autoscaling.ExitStandbyInput{
+		AutoScalingGroupName: autoscalingGroupName,
+		InstanceIds:          []*string{instanceID},
+	})
+
+	return err
+}
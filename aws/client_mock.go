@@ -3,11 +3,13 @@ package aws
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 // ConnectionMock is a aws mock client for testing purposes
@@ -28,6 +30,24 @@ func (c *ConnectionMock) DescribeInstanceByID(instanceID string) (*ec2.Instance,
 	return mockResponse.(*ec2.Instance), nil
 }
 
+// DescribeInstancesByIDs is a mock call for testing purposes. It replays a DescribeInstanceById record
+// per instanceID
+func (c *ConnectionMock) DescribeInstancesByIDs(instanceIDs []string) ([]*ec2.Instance, error) {
+
+	c.addRequests("DescribeInstancesByIDs", instanceIDs)
+
+	instances := []*ec2.Instance{}
+	for _, instanceID := range instanceIDs {
+		mockResponse, _ := c.replay(&ec2.Instance{}, "DescribeInstanceById")
+		instance := mockResponse.(*ec2.Instance)
+		if instance.InstanceId == nil {
+			instance.InstanceId = aws.String(instanceID)
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
 // DescribeInstancesByTag is a mock call for testing purposes
 func (c *ConnectionMock) DescribeInstancesByTag(tagKey string) ([]*ec2.Instance, error) {
 
@@ -42,6 +62,35 @@ func (c *ConnectionMock) DescribeAGsByPrefix(autoscalingGroupName string) ([]*au
 	return *mockResponse.(*[]*autoscaling.Group), nil
 }
 
+// DescribeAGsByTags is a mock call for testing purposes
+func (c *ConnectionMock) DescribeAGsByTags(tags map[string]string) ([]*autoscaling.Group, error) {
+
+	keys := []string{}
+	for key, value := range tags {
+		keys = append(keys, key+"="+value)
+	}
+	sort.Strings(keys)
+	c.addRequests("DescribeAGsByTags", keys)
+
+	mockResponse, _ := c.replay(&[]*autoscaling.Group{}, "DescribeAGByName")
+	return *mockResponse.(*[]*autoscaling.Group), nil
+}
+
+// DescribeAGByName is a mock call for testing purposes. It returns the group with the name from the
+// DescribeAGByName record
+func (c *ConnectionMock) DescribeAGByName(autoscalingGroupName string) (*autoscaling.Group, error) {
+
+	c.addRequests("DescribeAGByName", []string{autoscalingGroupName})
+
+	mockResponse, _ := c.replay(&[]*autoscaling.Group{}, "DescribeAGByName")
+	for _, autoscalingGroup := range *mockResponse.(*[]*autoscaling.Group) {
+		if *autoscalingGroup.AutoScalingGroupName == autoscalingGroupName {
+			return autoscalingGroup, nil
+		}
+	}
+	return nil, fmt.Errorf("No autoscaling group found with name %v", autoscalingGroupName)
+}
+
 // SetASGInstanceProtection is a mock call for testing purposes
 func (c *ConnectionMock) SetASGInstanceProtection(autoscalingGroupName *string, instanceIDs []*string) error {
 
@@ -69,30 +118,111 @@ func (c *ConnectionMock) SetInstanceTag(key, value, instanceID string) error {
 	return nil
 }
 
-// HasLifeCycleHook is a mock call for testing purposes
-func (c *ConnectionMock) HasLifeCycleHook(autoscalingGroupName string) (bool, error) {
+// DeleteInstanceTag is a mock call for testing purposes
+func (c *ConnectionMock) DeleteInstanceTag(key, instanceID string) error {
 
-	records, ok := c.Records["HasLifeCycleHook"]
-	if !ok {
-		return false, nil
+	c.addRequests("DeleteInstanceTag", []string{key, instanceID})
+	return nil
+}
+
+// TerminateASGInstance is a mock call for testing purposes
+func (c *ConnectionMock) TerminateASGInstance(instanceID *string, shouldDecrementDesiredCapacity bool) error {
+
+	c.addRequests("TerminateASGInstance", []string{*instanceID, fmt.Sprintf("%v", shouldDecrementDesiredCapacity)})
+	return nil
+}
+
+// DetachInstance is a mock call for testing purposes
+func (c *ConnectionMock) DetachInstance(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) error {
+
+	c.addRequests("DetachInstance", []string{*autoscalingGroupName, *instanceID,
+		fmt.Sprintf("%v", shouldDecrementDesiredCapacity)})
+	return nil
+}
+
+// TerminateInstance is a mock call for testing purposes
+func (c *ConnectionMock) TerminateInstance(instanceID *string) error {
+
+	c.addRequests("TerminateInstance", []string{*instanceID})
+	return nil
+}
+
+// EnterStandby is a mock call for testing purposes
+func (c *ConnectionMock) EnterStandby(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) error {
+
+	c.addRequests("EnterStandby", []string{*autoscalingGroupName, *instanceID,
+		fmt.Sprintf("%v", shouldDecrementDesiredCapacity)})
+	return nil
+}
+
+// ExitStandby is a mock call for testing purposes
+func (c *ConnectionMock) ExitStandby(autoscalingGroupName, instanceID *string) error {
+
+	c.addRequests("ExitStandby", []string{*autoscalingGroupName, *instanceID})
+	return nil
+}
+
+// DescribeLifeCycleHook is a mock call for testing purposes. It replays the DescribeLifeCycleHook records for
+// the deathnode lifecycle hook and the DescribeLaunchLifeCycleHook ones for the launch lifecycle hook. Without
+// records, the lifecycle hook doesn't exist
+func (c *ConnectionMock) DescribeLifeCycleHook(autoscalingGroupName,
+	lifecycleHookName string) (*autoscaling.LifecycleHook, error) {
+
+	templateFileName := "DescribeLifeCycleHook"
+	if lifecycleHookName == LaunchLifecycleHookName {
+		templateFileName = "DescribeLaunchLifeCycleHook"
 	}
 
-	hasLifeCycleHook := (*records)[0]
-	*records = (*records)[1:]
-	return hasLifeCycleHook == "true", nil
+	records, ok := c.Records[templateFileName]
+	if !ok || len(*records) == 0 {
+		return nil, nil
+	}
+
+	mockResponse, _ := c.replay(&autoscaling.LifecycleHook{}, templateFileName)
+	return mockResponse.(*autoscaling.LifecycleHook), nil
 }
 
 // PutLifeCycleHook is a mock call for testing purposes
-func (c *ConnectionMock) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error {
+func (c *ConnectionMock) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64,
+	defaultResult string) error {
+
+	c.addRequests("PutLifeCycleHook", []string{autoscalingGroupName, fmt.Sprintf("%d", *heartbeatTimeout), defaultResult})
+	return nil
+}
+
+// PutLaunchLifeCycleHook is a mock call for testing purposes
+func (c *ConnectionMock) PutLaunchLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error {
 
-	c.addRequests("PutLifeCycleHook", []string{autoscalingGroupName, fmt.Sprintf("%d", *heartbeatTimeout)})
+	c.addRequests("PutLaunchLifeCycleHook", []string{autoscalingGroupName, fmt.Sprintf("%d", *heartbeatTimeout)})
 	return nil
 }
 
-// CompleteLifecycleAction is a mock call for testing purposes
-func (c *ConnectionMock) CompleteLifecycleAction(autoscalingGroupName, instanceID *string) error {
+// DeleteLaunchLifeCycleHook is a mock call for testing purposes
+func (c *ConnectionMock) DeleteLaunchLifeCycleHook(autoscalingGroupName string) error {
 
-	c.addRequests("CompleteLifecycleAction", []string{*autoscalingGroupName, *instanceID})
+	c.addRequests("DeleteLaunchLifeCycleHook", []string{autoscalingGroupName})
+	return nil
+}
+
+// CompleteLaunchLifecycleAction is a mock call for testing purposes
+func (c *ConnectionMock) CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID *string, result string) error {
+
+	c.addRequests("CompleteLaunchLifecycleAction", []string{*autoscalingGroupName, *instanceID, result})
+	return nil
+}
+
+// CompleteLifecycleAction is a mock call for testing purposes. The lifecycle action token is only recorded
+// when set
+func (c *ConnectionMock) CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken *string,
+	result string) error {
+
+	parameters := []string{*autoscalingGroupName, *instanceID, result}
+	if lifecycleActionToken != nil {
+		parameters = append(parameters, *lifecycleActionToken)
+	}
+	c.addRequests("CompleteLifecycleAction", parameters)
 	return nil
 }
 
@@ -0,0 +1,338 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	log "github.com/sirupsen/logrus"
+)
+
+// DryRunClient wraps an aws client, forwarding the read-only calls and logging the mutating ones
+// instead of executing them. Tags set while in dry-run are stored in memory and applied to the
+// instances returned by the wrapped client, so later iterations behave as if they were set. The same
+// applies to deleted tags
+type DryRunClient struct {
+	client      ClientInterface
+	tags        map[string]map[string]string
+	deletedTags map[string]map[string]bool
+}
+
+// NewDryRunClient returns a DryRunClient wrapping an aws client
+func NewDryRunClient(client ClientInterface) *DryRunClient {
+
+	return &DryRunClient{
+		client:      client,
+		tags:        map[string]map[string]string{},
+		deletedTags: map[string]map[string]bool{},
+	}
+}
+
+// DescribeInstanceByID returns the instance that matches an instanceID, including dry-run tags
+func (c *DryRunClient) DescribeInstanceByID(instanceID string) (*ec2.Instance, error) {
+
+	instance, err := c.client.DescribeInstanceByID(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.applyTags(instanceID, instance)
+	return instance, nil
+}
+
+// DescribeInstancesByIDs returns the instances that match the instanceIDs, including dry-run tags
+func (c *DryRunClient) DescribeInstancesByIDs(instanceIDs []string) ([]*ec2.Instance, error) {
+
+	instances, err := c.client.DescribeInstancesByIDs(instanceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, instance := range instances {
+		c.applyTags(*instance.InstanceId, instance)
+	}
+	return instances, nil
+}
+
+// DescribeInstancesByTag return all instances with a certain tag set, including dry-run tags
+func (c *DryRunClient) DescribeInstancesByTag(tagKey string) ([]*ec2.Instance, error) {
+
+	instances, err := c.client.DescribeInstancesByTag(tagKey)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	taggedInstances := []*ec2.Instance{}
+	for _, instance := range instances {
+		c.applyTags(*instance.InstanceId, instance)
+		found[*instance.InstanceId] = true
+		if !c.deletedTags[*instance.InstanceId][tagKey] {
+			taggedInstances = append(taggedInstances, instance)
+		}
+	}
+	instances = taggedInstances
+
+	for instanceID, tags := range c.tags {
+		if _, ok := tags[tagKey]; !ok || found[instanceID] {
+			continue
+		}
+
+		instance, err := c.DescribeInstanceByID(instanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		if instance.State != nil && instance.State.Name != nil && *instance.State.Name != ec2.InstanceStateNameRunning {
+			continue
+		}
+		instance.InstanceId = aws.String(instanceID)
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// DescribeAGsByPrefix returns all autoscaling groups that matches a certain prefix
+func (c *DryRunClient) DescribeAGsByPrefix(autoscalingGroupPrefix string) ([]*autoscaling.Group, error) {
+	return c.client.DescribeAGsByPrefix(autoscalingGroupPrefix)
+}
+
+// DescribeAGsByTags returns all autoscaling groups that have all the tags
+func (c *DryRunClient) DescribeAGsByTags(tags map[string]string) ([]*autoscaling.Group, error) {
+	return c.client.DescribeAGsByTags(tags)
+}
+
+// DescribeAGByName returns the autoscaling group with the name
+func (c *DryRunClient) DescribeAGByName(autoscalingGroupName string) (*autoscaling.Group, error) {
+	return c.client.DescribeAGByName(autoscalingGroupName)
+}
+
+// DescribeLifeCycleHook returns a lifecycle hook of an autoscalingGroup, or nil if it doesn't exist
+func (c *DryRunClient) DescribeLifeCycleHook(autoscalingGroupName,
+	lifecycleHookName string) (*autoscaling.LifecycleHook, error) {
+	return c.client.DescribeLifeCycleHook(autoscalingGroupName, lifecycleHookName)
+}
+
+// RemoveASGInstanceProtection logs the instance protection removal without executing it
+func (c *DryRunClient) RemoveASGInstanceProtection(autoscalingGroupName, instanceID *string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": *autoscalingGroupName,
+		"instance":          *instanceID,
+	}).Info("Dry-run: would remove instance protection")
+	return nil
+}
+
+// SetASGInstanceProtection logs the instance protection change without executing it
+func (c *DryRunClient) SetASGInstanceProtection(autoscalingGroupName *string, instanceIDs []*string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": *autoscalingGroupName,
+		"instances":         aws.StringValueSlice(instanceIDs),
+	}).Info("Dry-run: would set instance protection")
+	return nil
+}
+
+// SetInstanceTag stores the tag in memory instead of setting it on the AWS instance
+func (c *DryRunClient) SetInstanceTag(key, value, instanceID string) error {
+
+	log.WithFields(log.Fields{
+		"instance": instanceID,
+		"key":      key,
+		"value":    value,
+	}).Info("Dry-run: would set instance tag")
+
+	if _, ok := c.tags[instanceID]; !ok {
+		c.tags[instanceID] = map[string]string{}
+	}
+	c.tags[instanceID][key] = value
+	delete(c.deletedTags[instanceID], key)
+	return nil
+}
+
+// DeleteInstanceTag stores the tag deletion in memory instead of removing it from the AWS instance
+func (c *DryRunClient) DeleteInstanceTag(key, instanceID string) error {
+
+	log.WithFields(log.Fields{
+		"instance": instanceID,
+		"key":      key,
+	}).Info("Dry-run: would delete instance tag")
+
+	if _, ok := c.deletedTags[instanceID]; !ok {
+		c.deletedTags[instanceID] = map[string]bool{}
+	}
+	c.deletedTags[instanceID][key] = true
+	delete(c.tags[instanceID], key)
+	return nil
+}
+
+// TerminateASGInstance logs the instance termination without executing it
+func (c *DryRunClient) TerminateASGInstance(instanceID *string, shouldDecrementDesiredCapacity bool) error {
+
+	log.WithFields(log.Fields{
+		"instance":                          *instanceID,
+		"should_decrement_desired_capacity": shouldDecrementDesiredCapacity,
+	}).Info("Dry-run: would terminate instance in autoscaling group")
+	return nil
+}
+
+// DetachInstance logs the instance detach without executing it
+func (c *DryRunClient) DetachInstance(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group":                 *autoscalingGroupName,
+		"instance":                          *instanceID,
+		"should_decrement_desired_capacity": shouldDecrementDesiredCapacity,
+	}).Info("Dry-run: would detach instance from autoscaling group")
+	return nil
+}
+
+// TerminateInstance logs the instance termination without executing it
+func (c *DryRunClient) TerminateInstance(instanceID *string) error {
+
+	log.WithFields(log.Fields{
+		"instance": *instanceID,
+	}).Info("Dry-run: would terminate instance")
+	return nil
+}
+
+// EnterStandby logs the move to Standby without executing it
+func (c *DryRunClient) EnterStandby(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group":                 *autoscalingGroupName,
+		"instance":                          *instanceID,
+		"should_decrement_desired_capacity": shouldDecrementDesiredCapacity,
+	}).Info("Dry-run: would move instance to Standby")
+	return nil
+}
+
+// ExitStandby logs the move out of Standby without executing it
+func (c *DryRunClient) ExitStandby(autoscalingGroupName, instanceID *string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": *autoscalingGroupName,
+		"instance":          *instanceID,
+	}).Info("Dry-run: would move instance out of Standby")
+	return nil
+}
+
+// PutLifeCycleHook logs the lifecycle hook creation without executing it
+func (c *DryRunClient) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64,
+	defaultResult string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": autoscalingGroupName,
+		"heartbeat_timeout": *heartbeatTimeout,
+		"default_result":    defaultResult,
+	}).Info("Dry-run: would put lifecycle hook")
+	return nil
+}
+
+// PutLaunchLifeCycleHook logs the launch lifecycle hook creation without executing it
+func (c *DryRunClient) PutLaunchLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": autoscalingGroupName,
+		"heartbeat_timeout": *heartbeatTimeout,
+	}).Info("Dry-run: would put launch lifecycle hook")
+	return nil
+}
+
+// DeleteLaunchLifeCycleHook logs the launch lifecycle hook removal without executing it
+func (c *DryRunClient) DeleteLaunchLifeCycleHook(autoscalingGroupName string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": autoscalingGroupName,
+	}).Info("Dry-run: would delete launch lifecycle hook")
+	return nil
+}
+
+// CompleteLaunchLifecycleAction logs the launch lifecycle action completion without executing it
+func (c *DryRunClient) CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID *string, result string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": *autoscalingGroupName,
+		"instance":          *instanceID,
+		"result":            result,
+	}).Info("Dry-run: would complete launch lifecycle action")
+	return nil
+}
+
+// CompleteLifecycleAction logs the lifecycle action completion without executing it
+func (c *DryRunClient) CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken *string,
+	result string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": *autoscalingGroupName,
+		"instance":          *instanceID,
+		"result":            result,
+	}).Info("Dry-run: would complete lifecycle action")
+	return nil
+}
+
+// RecordLifecycleActionHeartbeat logs the lifecycle heartbeat without executing it
+func (c *DryRunClient) RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID *string) error {
+
+	log.WithFields(log.Fields{
+		"autoscaling_group": *autoscalingGroupName,
+		"instance":          *instanceID,
+	}).Info("Dry-run: would record lifecycle action heartbeat")
+	return nil
+}
+
+func (c *DryRunClient) applyTags(instanceID string, instance *ec2.Instance) {
+
+	if deletedTags, ok := c.deletedTags[instanceID]; ok {
+		tags := []*ec2.Tag{}
+		for _, tag := range instance.Tags {
+			if !deletedTags[*tag.Key] {
+				tags = append(tags, tag)
+			}
+		}
+		instance.Tags = tags
+	}
+
+	tags, ok := c.tags[instanceID]
+	if !ok {
+		return
+	}
+
+	for key, value := range tags {
+		found := false
+		for _, tag := range instance.Tags {
+			if *tag.Key == key {
+				tag.Value = aws.String(value)
+				found = true
+			}
+		}
+		if !found {
+			instance.Tags = append(instance.Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+	}
+}
+
+// DryRunQueue wraps a queue, receiving its messages but only logging their deletion, so they are received
+// again once their visibility timeout expires
+type DryRunQueue struct {
+	queue QueueInterface
+}
+
+// NewDryRunQueue returns a DryRunQueue wrapping a queue
+func NewDryRunQueue(queue QueueInterface) *DryRunQueue {
+	return &DryRunQueue{queue: queue}
+}
+
+// ReceiveMessages returns the messages received from the wrapped queue
+func (q *DryRunQueue) ReceiveMessages() ([]*QueueMessage, error) {
+	return q.queue.ReceiveMessages()
+}
+
+// DeleteMessage logs the message deletion without executing it
+func (q *DryRunQueue) DeleteMessage(receiptHandle string) error {
+
+	log.WithField("receipt_handle", receiptHandle).Info("Dry-run: would delete queue message")
+	return nil
+}
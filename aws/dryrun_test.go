@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDryRunClient(t *testing.T) {
+
+	Convey("When using a dry-run client", t, func() {
+		awsConn := &ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":   {"node1", "node1"},
+				"DescribeInstancesByTag": {"default", "default"},
+			},
+		}
+		dryRunConn := NewDryRunClient(awsConn)
+
+		Convey("mutating calls should not reach the wrapped client", func() {
+			instanceID, autoscalingGroupName := "i-34719eb8", "some-Autoscaling-Group"
+			heartbeatTimeout := int64(3600)
+			dryRunConn.SetASGInstanceProtection(&autoscalingGroupName, []*string{&instanceID})
+			dryRunConn.RemoveASGInstanceProtection(&autoscalingGroupName, &instanceID)
+			dryRunConn.PutLifeCycleHook(autoscalingGroupName, &heartbeatTimeout, LifecycleActionContinue)
+			dryRunConn.DeleteLaunchLifeCycleHook(autoscalingGroupName)
+			dryRunConn.CompleteLifecycleAction(&autoscalingGroupName, &instanceID, nil, LifecycleActionContinue)
+			dryRunConn.RecordLifecycleActionHeartbeat(&autoscalingGroupName, &instanceID)
+			dryRunConn.SetInstanceTag("DEATH_NODE_MARK", "1190995200", instanceID)
+			dryRunConn.DeleteInstanceTag("DEATH_NODE_MARK", instanceID)
+			dryRunConn.TerminateASGInstance(&instanceID, false)
+			dryRunConn.DetachInstance(&autoscalingGroupName, &instanceID, true)
+			dryRunConn.TerminateInstance(&instanceID)
+			dryRunConn.EnterStandby(&autoscalingGroupName, &instanceID, true)
+			dryRunConn.ExitStandby(&autoscalingGroupName, &instanceID)
+			So(awsConn.Requests, ShouldBeEmpty)
+		})
+		Convey("tags set should be returned as if they were applied", func() {
+			dryRunConn.SetInstanceTag("DEATH_NODE_MARK", "1190995200", "i-34719eb8")
+
+			instance, _ := dryRunConn.DescribeInstanceByID("i-34719eb8")
+			So(instance.Tags, ShouldHaveLength, 1)
+			So(*instance.Tags[0].Key, ShouldEqual, "DEATH_NODE_MARK")
+			So(*instance.Tags[0].Value, ShouldEqual, "1190995200")
+
+			instances, _ := dryRunConn.DescribeInstancesByTag("DEATH_NODE_MARK")
+			So(instances, ShouldHaveLength, 1)
+			So(*instances[0].InstanceId, ShouldEqual, "i-34719eb8")
+		})
+		Convey("instances without dry-run tags should not be returned by tag", func() {
+			instances, _ := dryRunConn.DescribeInstancesByTag("DEATH_NODE_MARK")
+			So(instances, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package aws
+
+import (
+	"time"
+
+	"github.com/alanbover/deathnode/metrics"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const metricsClientName = "aws"
+
+// InstrumentedClient wraps an aws client, recording the number of calls, errors and latency of
+// every method
+type InstrumentedClient struct {
+	client ClientInterface
+}
+
+// NewInstrumentedClient returns an InstrumentedClient wrapping an aws client
+func NewInstrumentedClient(client ClientInterface) *InstrumentedClient {
+	return &InstrumentedClient{client: client}
+}
+
+// DescribeInstanceByID returns the instance that matches an instanceID
+func (c *InstrumentedClient) DescribeInstanceByID(instanceID string) (instance *ec2.Instance, err error) {
+
+	defer observe("DescribeInstanceByID", time.Now(), &err)
+	return c.client.DescribeInstanceByID(instanceID)
+}
+
+// DescribeInstancesByIDs returns the instances that match the instanceIDs
+func (c *InstrumentedClient) DescribeInstancesByIDs(instanceIDs []string) (instances []*ec2.Instance, err error) {
+
+	defer observe("DescribeInstancesByIDs", time.Now(), &err)
+	return c.client.DescribeInstancesByIDs(instanceIDs)
+}
+
+// DescribeInstancesByTag return all instances with a certain tag set
+func (c *InstrumentedClient) DescribeInstancesByTag(tagKey string) (instances []*ec2.Instance, err error) {
+
+	defer observe("DescribeInstancesByTag", time.Now(), &err)
+	return c.client.DescribeInstancesByTag(tagKey)
+}
+
+// DescribeAGsByPrefix returns the autoscaling groups that match a prefix
+func (c *InstrumentedClient) DescribeAGsByPrefix(autoscalingGroupPrefix string) (groups []*autoscaling.Group, err error) {
+
+	defer observe("DescribeAGsByPrefix", time.Now(), &err)
+	return c.client.DescribeAGsByPrefix(autoscalingGroupPrefix)
+}
+
+// DescribeAGsByTags returns the autoscaling groups that have all the tags
+func (c *InstrumentedClient) DescribeAGsByTags(tags map[string]string) (groups []*autoscaling.Group, err error) {
+
+	defer observe("DescribeAGsByTags", time.Now(), &err)
+	return c.client.DescribeAGsByTags(tags)
+}
+
+// DescribeAGByName returns the autoscaling group with the name
+func (c *InstrumentedClient) DescribeAGByName(autoscalingGroupName string) (group *autoscaling.Group, err error) {
+
+	defer observe("DescribeAGByName", time.Now(), &err)
+	return c.client.DescribeAGByName(autoscalingGroupName)
+}
+
+// DescribeLifeCycleHook returns a lifecycle hook of an autoscaling group, or nil if it doesn't exist
+func (c *InstrumentedClient) DescribeLifeCycleHook(autoscalingGroupName,
+	lifecycleHookName string) (hook *autoscaling.LifecycleHook, err error) {
+
+	defer observe("DescribeLifeCycleHook", time.Now(), &err)
+	return c.client.DescribeLifeCycleHook(autoscalingGroupName, lifecycleHookName)
+}
+
+// RemoveASGInstanceProtection removes the scale-in protection of an instance
+func (c *InstrumentedClient) RemoveASGInstanceProtection(autoscalingGroupName, instanceID *string) (err error) {
+
+	defer observe("RemoveASGInstanceProtection", time.Now(), &err)
+	return c.client.RemoveASGInstanceProtection(autoscalingGroupName, instanceID)
+}
+
+// SetASGInstanceProtection sets the scale-in protection of the autoscaling group and its instances
+func (c *InstrumentedClient) SetASGInstanceProtection(autoscalingGroupName *string, instanceIDs []*string) (err error) {
+
+	defer observe("SetASGInstanceProtection", time.Now(), &err)
+	return c.client.SetASGInstanceProtection(autoscalingGroupName, instanceIDs)
+}
+
+// SetInstanceTag sets a tag on an instance
+func (c *InstrumentedClient) SetInstanceTag(key, value, instanceID string) (err error) {
+
+	defer observe("SetInstanceTag", time.Now(), &err)
+	return c.client.SetInstanceTag(key, value, instanceID)
+}
+
+// DeleteInstanceTag removes a tag from an instance
+func (c *InstrumentedClient) DeleteInstanceTag(key, instanceID string) (err error) {
+
+	defer observe("DeleteInstanceTag", time.Now(), &err)
+	return c.client.DeleteInstanceTag(key, instanceID)
+}
+
+// TerminateASGInstance terminates an instance through its autoscaling group
+func (c *InstrumentedClient) TerminateASGInstance(instanceID *string, shouldDecrementDesiredCapacity bool) (err error) {
+
+	defer observe("TerminateASGInstance", time.Now(), &err)
+	return c.client.TerminateASGInstance(instanceID, shouldDecrementDesiredCapacity)
+}
+
+// DetachInstance removes an instance from its autoscaling group
+func (c *InstrumentedClient) DetachInstance(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) (err error) {
+
+	defer observe("DetachInstance", time.Now(), &err)
+	return c.client.DetachInstance(autoscalingGroupName, instanceID, shouldDecrementDesiredCapacity)
+}
+
+// TerminateInstance terminates an EC2 instance
+func (c *InstrumentedClient) TerminateInstance(instanceID *string) (err error) {
+
+	defer observe("TerminateInstance", time.Now(), &err)
+	return c.client.TerminateInstance(instanceID)
+}
+
+// EnterStandby moves an instance of an autoscaling group to Standby
+func (c *InstrumentedClient) EnterStandby(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) (err error) {
+
+	defer observe("EnterStandby", time.Now(), &err)
+	return c.client.EnterStandby(autoscalingGroupName, instanceID, shouldDecrementDesiredCapacity)
+}
+
+// ExitStandby moves an instance in Standby back to service
+func (c *InstrumentedClient) ExitStandby(autoscalingGroupName, instanceID *string) (err error) {
+
+	defer observe("ExitStandby", time.Now(), &err)
+	return c.client.ExitStandby(autoscalingGroupName, instanceID)
+}
+
+// PutLifeCycleHook puts the deathnode lifecycle hook on an autoscaling group
+func (c *InstrumentedClient) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64,
+	defaultResult string) (err error) {
+
+	defer observe("PutLifeCycleHook", time.Now(), &err)
+	return c.client.PutLifeCycleHook(autoscalingGroupName, heartbeatTimeout, defaultResult)
+}
+
+// PutLaunchLifeCycleHook puts the deathnode launch lifecycle hook on an autoscaling group
+func (c *InstrumentedClient) PutLaunchLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) (err error) {
+
+	defer observe("PutLaunchLifeCycleHook", time.Now(), &err)
+	return c.client.PutLaunchLifeCycleHook(autoscalingGroupName, heartbeatTimeout)
+}
+
+// DeleteLaunchLifeCycleHook removes the deathnode launch lifecycle hook from an autoscaling group
+func (c *InstrumentedClient) DeleteLaunchLifeCycleHook(autoscalingGroupName string) (err error) {
+
+	defer observe("DeleteLaunchLifeCycleHook", time.Now(), &err)
+	return c.client.DeleteLaunchLifeCycleHook(autoscalingGroupName)
+}
+
+// CompleteLaunchLifecycleAction completes the launch lifecycle action of an instance
+func (c *InstrumentedClient) CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID *string,
+	result string) (err error) {
+
+	defer observe("CompleteLaunchLifecycleAction", time.Now(), &err)
+	return c.client.CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID, result)
+}
+
+// CompleteLifecycleAction completes the lifecycle action of an instance
+func (c *InstrumentedClient) CompleteLifecycleAction(autoscalingGroupName, instanceID,
+	lifecycleActionToken *string, result string) (err error) {
+
+	defer observe("CompleteLifecycleAction", time.Now(), &err)
+	return c.client.CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken, result)
+}
+
+// RecordLifecycleActionHeartbeat resets the timeout of the lifecycle action of an instance
+func (c *InstrumentedClient) RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID *string) (err error) {
+
+	defer observe("RecordLifecycleActionHeartbeat", time.Now(), &err)
+	return c.client.RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID)
+}
+
+// observe records a call. Throttled calls are counted by the RetryingClient, not as errors
+func observe(method string, start time.Time, err *error) {
+
+	observedErr := *err
+	if IsThrottle(observedErr) {
+		observedErr = nil
+	}
+	metrics.ObserveClientRequest(metricsClientName, method, start, observedErr)
+}
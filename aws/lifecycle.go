@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// LifecycleHookName is the name of the lifecycle hook deathnode puts on the autoscaling groups
+	LifecycleHookName = "DEATHNODE"
+	// LifecycleTransitionTerminating is the transition of the deathnode lifecycle hook
+	LifecycleTransitionTerminating = "autoscaling:EC2_INSTANCE_TERMINATING"
+	// LaunchLifecycleHookName is the name of the optional lifecycle hook holding new instances in Pending:Wait
+	LaunchLifecycleHookName = "DEATHNODE_LAUNCH"
+	// LifecycleTransitionLaunching is the transition of the deathnode launch lifecycle hook
+	LifecycleTransitionLaunching = "autoscaling:EC2_INSTANCE_LAUNCHING"
+	// LifecycleActionContinue lets the instance go on with the lifecycle transition
+	LifecycleActionContinue = "CONTINUE"
+	// LifecycleActionAbandon terminates the instance, or stops its launch
+	LifecycleActionAbandon = "ABANDON"
+)
+
+// LifecycleEvent is a lifecycle action notification sent by an autoscaling group
+type LifecycleEvent struct {
+	AutoscalingGroupName string `json:"AutoScalingGroupName"`
+	InstanceID           string `json:"EC2InstanceId"`
+	LifecycleTransition  string `json:"LifecycleTransition"`
+	LifecycleHookName    string `json:"LifecycleHookName"`
+	LifecycleActionToken string `json:"LifecycleActionToken"`
+}
+
+// lifecycleMessage holds the fields of all the supported message formats: the notification sent by the
+// autoscaling group to SQS, the same notification wrapped by SNS, and the EventBridge event
+type lifecycleMessage struct {
+	LifecycleEvent
+	Type    string          `json:"Type"`
+	Message string          `json:"Message"`
+	Detail  *LifecycleEvent `json:"detail"`
+}
+
+// ParseLifecycleMessage returns the lifecycle event in a queue message body. Messages that are not lifecycle
+// action notifications, like the autoscaling:TEST_NOTIFICATION ones, return a nil event
+func ParseLifecycleMessage(body string) (*LifecycleEvent, error) {
+
+	message := &lifecycleMessage{}
+	if err := json.Unmarshal([]byte(body), message); err != nil {
+		return nil, fmt.Errorf("Invalid lifecycle message: %s", err)
+	}
+
+	if message.Type == "Notification" && message.Message != "" {
+		return ParseLifecycleMessage(message.Message)
+	}
+
+	event := &message.LifecycleEvent
+	if message.Detail != nil {
+		event = message.Detail
+	}
+	if event.LifecycleTransition == "" {
+		return nil, nil
+	}
+	if event.AutoscalingGroupName == "" || event.InstanceID == "" {
+		return nil, fmt.Errorf("Lifecycle message without autoscaling group or instance: %s", body)
+	}
+	return event, nil
+}
@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseLifecycleMessage(t *testing.T) {
+
+	Convey("When parsing a lifecycle notification", t, func() {
+		expected := &LifecycleEvent{
+			AutoscalingGroupName: "some-Autoscaling-Group",
+			InstanceID:           "i-34719eb8",
+			LifecycleTransition:  LifecycleTransitionTerminating,
+			LifecycleHookName:    LifecycleHookName,
+			LifecycleActionToken: "71514b9d-6a40-4b26-8523-05e7ee35fa40",
+		}
+		notification := `{"Service":"AWS Auto Scaling","AutoScalingGroupName":"some-Autoscaling-Group",` +
+			`"EC2InstanceId":"i-34719eb8","LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING",` +
+			`"LifecycleHookName":"DEATHNODE","LifecycleActionToken":"71514b9d-6a40-4b26-8523-05e7ee35fa40"}`
+
+		Convey("sent by the autoscaling group to SQS, it should return the event", func() {
+			event, err := ParseLifecycleMessage(notification)
+			So(err, ShouldBeNil)
+			So(event, ShouldResemble, expected)
+		})
+		Convey("wrapped by SNS, it should return the event", func() {
+			event, err := ParseLifecycleMessage(`{"Type":"Notification","MessageId":"1","Message":` +
+				`"{\"AutoScalingGroupName\":\"some-Autoscaling-Group\",\"EC2InstanceId\":\"i-34719eb8\",` +
+				`\"LifecycleTransition\":\"autoscaling:EC2_INSTANCE_TERMINATING\",\"LifecycleHookName\":\"DEATHNODE\",` +
+				`\"LifecycleActionToken\":\"71514b9d-6a40-4b26-8523-05e7ee35fa40\"}"}`)
+			So(err, ShouldBeNil)
+			So(event, ShouldResemble, expected)
+		})
+		Convey("sent by EventBridge, it should return the event", func() {
+			event, err := ParseLifecycleMessage(`{"version":"0","detail-type":"EC2 Instance-terminate Lifecycle Action",` +
+				`"source":"aws.autoscaling","detail":` + notification + `}`)
+			So(err, ShouldBeNil)
+			So(event, ShouldResemble, expected)
+		})
+		Convey("that is a test notification, it should be ignored", func() {
+			event, err := ParseLifecycleMessage(`{"Event":"autoscaling:TEST_NOTIFICATION",` +
+				`"AutoScalingGroupName":"some-Autoscaling-Group"}`)
+			So(err, ShouldBeNil)
+			So(event, ShouldBeNil)
+		})
+		Convey("that is not JSON, it should fail", func() {
+			_, err := ParseLifecycleMessage("not json")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
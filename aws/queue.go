@@ -0,0 +1,15 @@
+package aws
+
+// QueueMessage is a message received from a queue. ReceiptHandle identifies the reception, to delete it
+type QueueMessage struct {
+	ID            string
+	Body          string
+	ReceiptHandle string
+}
+
+// QueueInterface implements a queue receiving the lifecycle notifications of the autoscaling groups.
+// Received messages not deleted are received again later
+type QueueInterface interface {
+	ReceiveMessages() ([]*QueueMessage, error)
+	DeleteMessage(receiptHandle string) error
+}
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryQueue is an in-memory queue for testing purposes. Received messages stay in flight until they are
+// deleted or released back to the queue
+type MemoryQueue struct {
+	mutex      sync.Mutex
+	messages   []*QueueMessage
+	inFlight   map[string]*QueueMessage
+	sent       int
+	receptions int
+}
+
+// Send adds a message to the queue
+func (q *MemoryQueue) Send(body string) {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.sent++
+	q.messages = append(q.messages, &QueueMessage{ID: fmt.Sprintf("message-%d", q.sent), Body: body})
+}
+
+// ReceiveMessages returns all the queued messages, moving them in flight
+func (q *MemoryQueue) ReceiveMessages() ([]*QueueMessage, error) {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.inFlight == nil {
+		q.inFlight = map[string]*QueueMessage{}
+	}
+
+	received := []*QueueMessage{}
+	for _, message := range q.messages {
+		q.receptions++
+		reception := &QueueMessage{
+			ID:            message.ID,
+			Body:          message.Body,
+			ReceiptHandle: fmt.Sprintf("%s-%d", message.ID, q.receptions),
+		}
+		q.inFlight[reception.ReceiptHandle] = message
+		received = append(received, reception)
+	}
+	q.messages = nil
+	return received, nil
+}
+
+// DeleteMessage removes a message in flight
+func (q *MemoryQueue) DeleteMessage(receiptHandle string) error {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.inFlight[receiptHandle]; !ok {
+		return fmt.Errorf("Receipt handle %s not found", receiptHandle)
+	}
+	delete(q.inFlight, receiptHandle)
+	return nil
+}
+
+// Release moves the messages in flight back to the queue, as when their visibility timeout expires
+func (q *MemoryQueue) Release() {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for receiptHandle, message := range q.inFlight {
+		q.messages = append(q.messages, message)
+		delete(q.inFlight, receiptHandle)
+	}
+}
+
+// Len returns the number of messages not deleted yet, queued or in flight
+func (q *MemoryQueue) Len() int {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.messages) + len(q.inFlight)
+}
@@ -0,0 +1,363 @@
+package aws
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/alanbover/deathnode/metrics"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/benbjohnson/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+// throttleCodes are the AWS error codes returned when a request has been throttled
+var throttleCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"RequestLimitExceeded":                   true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+}
+
+// RetryConf stores the retry and rate limit settings of a RetryingClient
+type RetryConf struct {
+	// MaxRetries is the number of times a failed call is retried. 0 disables retries
+	MaxRetries int
+	// BaseDelay is the upper bound of the first backoff, doubled on every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between retries
+	MaxDelay time.Duration
+	// RateLimit is the maximum number of calls per second. 0 disables the rate limiter
+	RateLimit float64
+	// Burst is the number of calls allowed above RateLimit after an idle period
+	Burst int
+}
+
+// RetryingClient wraps an aws client, waiting for the rate limiter before every call and retrying the
+// throttled, server side and connection errors with exponential backoff and full jitter
+type RetryingClient struct {
+	client  ClientInterface
+	conf    RetryConf
+	limiter *tokenBucket
+	clock   clock.Clock
+	sleep   func(time.Duration)
+	jitter  func(time.Duration) time.Duration
+}
+
+// NewRetryingClient returns a RetryingClient wrapping an aws client
+func NewRetryingClient(client ClientInterface, conf RetryConf, clk clock.Clock) *RetryingClient {
+
+	retryingClient := &RetryingClient{
+		client: client,
+		conf:   conf,
+		clock:  clk,
+		sleep:  clk.Sleep,
+		jitter: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(max) + 1))
+		},
+	}
+	if conf.RateLimit > 0 {
+		retryingClient.limiter = newTokenBucket(conf.RateLimit, conf.Burst, clk)
+	}
+	return retryingClient
+}
+
+// IsThrottle returns true if the error means AWS throttled the request
+func IsThrottle(err error) bool {
+
+	awsErr, ok := err.(awserr.Error)
+	return ok && throttleCodes[awsErr.Code()]
+}
+
+// isRetryable returns true for the errors that may succeed if the request is sent again
+func isRetryable(err error) bool {
+
+	if IsThrottle(err) {
+		return true
+	}
+	if requestFailure, ok := err.(awserr.RequestFailure); ok && requestFailure.StatusCode() >= 500 {
+		return true
+	}
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "RequestError"
+}
+
+// call runs f until it succeeds, fails with a non retryable error or runs out of retries
+func (c *RetryingClient) call(method string, f func() error) error {
+
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			c.sleep(c.limiter.reserve())
+		}
+
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		if IsThrottle(err) {
+			metrics.ClientThrottles.Inc(metricsClientName, method)
+		}
+		if attempt >= c.conf.MaxRetries || !isRetryable(err) {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		log.Debugf("AWS call %s failed (%s). Retrying in %s", method, err, delay)
+		metrics.ClientRetries.Inc(metricsClientName, method)
+		c.sleep(delay)
+	}
+}
+
+// backoff returns a random delay between 0 and BaseDelay*2^attempt, capped to MaxDelay
+func (c *RetryingClient) backoff(attempt int) time.Duration {
+
+	maxDelay := float64(c.conf.BaseDelay) * math.Pow(2, float64(attempt))
+	if c.conf.MaxDelay > 0 && maxDelay > float64(c.conf.MaxDelay) {
+		maxDelay = float64(c.conf.MaxDelay)
+	}
+	return c.jitter(time.Duration(maxDelay))
+}
+
+// DescribeInstanceByID returns the instance that matches an instanceID
+func (c *RetryingClient) DescribeInstanceByID(instanceID string) (instance *ec2.Instance, err error) {
+
+	err = c.call("DescribeInstanceByID", func() error {
+		instance, err = c.client.DescribeInstanceByID(instanceID)
+		return err
+	})
+	return instance, err
+}
+
+// DescribeInstancesByIDs returns the instances that match the instanceIDs
+func (c *RetryingClient) DescribeInstancesByIDs(instanceIDs []string) (instances []*ec2.Instance, err error) {
+
+	err = c.call("DescribeInstancesByIDs", func() error {
+		instances, err = c.client.DescribeInstancesByIDs(instanceIDs)
+		return err
+	})
+	return instances, err
+}
+
+// DescribeInstancesByTag return all instances with a certain tag set
+func (c *RetryingClient) DescribeInstancesByTag(tagKey string) (instances []*ec2.Instance, err error) {
+
+	err = c.call("DescribeInstancesByTag", func() error {
+		instances, err = c.client.DescribeInstancesByTag(tagKey)
+		return err
+	})
+	return instances, err
+}
+
+// DescribeAGsByPrefix returns the autoscaling groups that match a prefix
+func (c *RetryingClient) DescribeAGsByPrefix(autoscalingGroupPrefix string) (groups []*autoscaling.Group, err error) {
+
+	err = c.call("DescribeAGsByPrefix", func() error {
+		groups, err = c.client.DescribeAGsByPrefix(autoscalingGroupPrefix)
+		return err
+	})
+	return groups, err
+}
+
+// DescribeAGsByTags returns the autoscaling groups that have all the tags
+func (c *RetryingClient) DescribeAGsByTags(tags map[string]string) (groups []*autoscaling.Group, err error) {
+
+	err = c.call("DescribeAGsByTags", func() error {
+		groups, err = c.client.DescribeAGsByTags(tags)
+		return err
+	})
+	return groups, err
+}
+
+// DescribeAGByName returns the autoscaling group with the name
+func (c *RetryingClient) DescribeAGByName(autoscalingGroupName string) (group *autoscaling.Group, err error) {
+
+	err = c.call("DescribeAGByName", func() error {
+		group, err = c.client.DescribeAGByName(autoscalingGroupName)
+		return err
+	})
+	return group, err
+}
+
+// DescribeLifeCycleHook returns a lifecycle hook of an autoscaling group, or nil if it doesn't exist
+func (c *RetryingClient) DescribeLifeCycleHook(autoscalingGroupName,
+	lifecycleHookName string) (hook *autoscaling.LifecycleHook, err error) {
+
+	err = c.call("DescribeLifeCycleHook", func() error {
+		hook, err = c.client.DescribeLifeCycleHook(autoscalingGroupName, lifecycleHookName)
+		return err
+	})
+	return hook, err
+}
+
+// RemoveASGInstanceProtection removes the scale-in protection of an instance
+func (c *RetryingClient) RemoveASGInstanceProtection(autoscalingGroupName, instanceID *string) error {
+
+	return c.call("RemoveASGInstanceProtection", func() error {
+		return c.client.RemoveASGInstanceProtection(autoscalingGroupName, instanceID)
+	})
+}
+
+// SetASGInstanceProtection sets the scale-in protection of the autoscaling group and its instances
+func (c *RetryingClient) SetASGInstanceProtection(autoscalingGroupName *string, instanceIDs []*string) error {
+
+	return c.call("SetASGInstanceProtection", func() error {
+		return c.client.SetASGInstanceProtection(autoscalingGroupName, instanceIDs)
+	})
+}
+
+// SetInstanceTag sets a tag on an instance
+func (c *RetryingClient) SetInstanceTag(key, value, instanceID string) error {
+
+	return c.call("SetInstanceTag", func() error {
+		return c.client.SetInstanceTag(key, value, instanceID)
+	})
+}
+
+// DeleteInstanceTag removes a tag from an instance
+func (c *RetryingClient) DeleteInstanceTag(key, instanceID string) error {
+
+	return c.call("DeleteInstanceTag", func() error {
+		return c.client.DeleteInstanceTag(key, instanceID)
+	})
+}
+
+// TerminateASGInstance terminates an instance through its autoscaling group
+func (c *RetryingClient) TerminateASGInstance(instanceID *string, shouldDecrementDesiredCapacity bool) error {
+
+	return c.call("TerminateASGInstance", func() error {
+		return c.client.TerminateASGInstance(instanceID, shouldDecrementDesiredCapacity)
+	})
+}
+
+// DetachInstance removes an instance from its autoscaling group
+func (c *RetryingClient) DetachInstance(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) error {
+
+	return c.call("DetachInstance", func() error {
+		return c.client.DetachInstance(autoscalingGroupName, instanceID, shouldDecrementDesiredCapacity)
+	})
+}
+
+// TerminateInstance terminates an EC2 instance
+func (c *RetryingClient) TerminateInstance(instanceID *string) error {
+
+	return c.call("TerminateInstance", func() error {
+		return c.client.TerminateInstance(instanceID)
+	})
+}
+
+// EnterStandby moves an instance of an autoscaling group to Standby
+func (c *RetryingClient) EnterStandby(autoscalingGroupName, instanceID *string,
+	shouldDecrementDesiredCapacity bool) error {
+
+	return c.call("EnterStandby", func() error {
+		return c.client.EnterStandby(autoscalingGroupName, instanceID, shouldDecrementDesiredCapacity)
+	})
+}
+
+// ExitStandby moves an instance in Standby back to service
+func (c *RetryingClient) ExitStandby(autoscalingGroupName, instanceID *string) error {
+
+	return c.call("ExitStandby", func() error {
+		return c.client.ExitStandby(autoscalingGroupName, instanceID)
+	})
+}
+
+// PutLifeCycleHook puts the deathnode lifecycle hook on an autoscaling group
+func (c *RetryingClient) PutLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64,
+	defaultResult string) error {
+
+	return c.call("PutLifeCycleHook", func() error {
+		return c.client.PutLifeCycleHook(autoscalingGroupName, heartbeatTimeout, defaultResult)
+	})
+}
+
+// PutLaunchLifeCycleHook puts the deathnode launch lifecycle hook on an autoscaling group
+func (c *RetryingClient) PutLaunchLifeCycleHook(autoscalingGroupName string, heartbeatTimeout *int64) error {
+
+	return c.call("PutLaunchLifeCycleHook", func() error {
+		return c.client.PutLaunchLifeCycleHook(autoscalingGroupName, heartbeatTimeout)
+	})
+}
+
+// DeleteLaunchLifeCycleHook removes the deathnode launch lifecycle hook from an autoscaling group
+func (c *RetryingClient) DeleteLaunchLifeCycleHook(autoscalingGroupName string) error {
+
+	return c.call("DeleteLaunchLifeCycleHook", func() error {
+		return c.client.DeleteLaunchLifeCycleHook(autoscalingGroupName)
+	})
+}
+
+// CompleteLaunchLifecycleAction completes the launch lifecycle action of an instance
+func (c *RetryingClient) CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID *string, result string) error {
+
+	return c.call("CompleteLaunchLifecycleAction", func() error {
+		return c.client.CompleteLaunchLifecycleAction(autoscalingGroupName, instanceID, result)
+	})
+}
+
+// CompleteLifecycleAction completes the lifecycle action of an instance
+func (c *RetryingClient) CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken *string,
+	result string) error {
+
+	return c.call("CompleteLifecycleAction", func() error {
+		return c.client.CompleteLifecycleAction(autoscalingGroupName, instanceID, lifecycleActionToken, result)
+	})
+}
+
+// RecordLifecycleActionHeartbeat resets the timeout of the lifecycle action of an instance
+func (c *RetryingClient) RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID *string) error {
+
+	return c.call("RecordLifecycleActionHeartbeat", func() error {
+		return c.client.RecordLifecycleActionHeartbeat(autoscalingGroupName, instanceID)
+	})
+}
+
+// tokenBucket is a rate limiter allowing rate calls per second, with bursts of up to burst calls
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  clock.Clock
+}
+
+func newTokenBucket(rate float64, burst int, clk clock.Clock) *tokenBucket {
+
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clk.Now(),
+		clock:  clk,
+	}
+}
+
+// reserve takes a token, returning how long the caller must wait before using it
+func (b *tokenBucket) reserve() time.Duration {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
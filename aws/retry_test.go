@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alanbover/deathnode/metrics"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/benbjohnson/clock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// failingClient fails DescribeInstanceByID with the given errors, in order, before succeeding
+type failingClient struct {
+	*ConnectionMock
+	errors []error
+	calls  int
+}
+
+func (c *failingClient) DescribeInstanceByID(instanceID string) (*ec2.Instance, error) {
+
+	c.calls++
+	if c.calls <= len(c.errors) {
+		return nil, c.errors[c.calls-1]
+	}
+	return &ec2.Instance{}, nil
+}
+
+func TestRetryingClient(t *testing.T) {
+
+	Convey("When calling AWS through a retrying client", t, func() {
+		throttle := awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil)
+		serverError := awserr.NewRequestFailure(awserr.New("InternalError", "Internal error", nil), 500, "id")
+		clockMock := clock.NewMock()
+		awsConn := &failingClient{ConnectionMock: &ConnectionMock{}}
+		sleeps := []time.Duration{}
+
+		newClient := func(conf RetryConf) *RetryingClient {
+			client := NewRetryingClient(awsConn, conf, clockMock)
+			client.sleep = func(delay time.Duration) {
+				sleeps = append(sleeps, delay)
+				clockMock.Add(delay)
+			}
+			client.jitter = func(max time.Duration) time.Duration { return max }
+			return client
+		}
+		retryConf := RetryConf{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+
+		Convey("throttled and server errors should be retried with a capped exponential backoff", func() {
+			awsConn.errors = []error{throttle, serverError, throttle}
+			throttlesBefore := metrics.ClientThrottles.Value("aws", "DescribeInstanceByID")
+
+			_, err := newClient(retryConf).DescribeInstanceByID("i-34719eb8")
+			So(err, ShouldBeNil)
+			So(awsConn.calls, ShouldEqual, 4)
+			So(sleeps, ShouldResemble, []time.Duration{
+				100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond})
+			So(metrics.ClientThrottles.Value("aws", "DescribeInstanceByID")-throttlesBefore, ShouldEqual, 2)
+		})
+		Convey("the last error should be returned once retries are exhausted", func() {
+			awsConn.errors = []error{throttle, throttle, throttle, throttle, throttle}
+
+			_, err := newClient(retryConf).DescribeInstanceByID("i-34719eb8")
+			So(err, ShouldEqual, throttle)
+			So(awsConn.calls, ShouldEqual, 4)
+		})
+		Convey("other errors should not be retried", func() {
+			notFound := awserr.New("InvalidInstanceID.NotFound", "Not found", nil)
+			awsConn.errors = []error{notFound, errors.New("unknown")}
+
+			_, err := newClient(retryConf).DescribeInstanceByID("i-34719eb8")
+			So(err, ShouldEqual, notFound)
+			So(awsConn.calls, ShouldEqual, 1)
+		})
+		Convey("calls above the rate limit should wait for a token", func() {
+			client := newClient(RetryConf{RateLimit: 2, Burst: 2})
+			for i := 0; i < 4; i++ {
+				client.DescribeInstanceByID("i-34719eb8")
+			}
+			So(sleeps, ShouldResemble, []time.Duration{0, 0, 500 * time.Millisecond, 500 * time.Millisecond})
+		})
+	})
+}
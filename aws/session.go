@@ -18,6 +18,7 @@ type sessionParameters struct {
 	region     string
 	iamRole    string
 	iamSession string
+	externalID string
 }
 
 func newAwsSession(parameters *sessionParameters) (*session.Session, error) {
@@ -25,24 +26,26 @@ func newAwsSession(parameters *sessionParameters) (*session.Session, error) {
 		return nil, errors.New("missing aws region (required)")
 	}
 
-	sess := session.New(&aws.Config{Region: aws.String(parameters.region)})
+	// Retries are handled by the RetryingClient
+	sess := session.New(&aws.Config{Region: aws.String(parameters.region), MaxRetries: aws.Int(0)})
 
 	if parameters.accessKey != "" && parameters.secretKey != "" {
 		sess = session.New(&aws.Config{
 			Region:      aws.String(parameters.region),
 			Credentials: credentials.NewStaticCredentials(parameters.accessKey, parameters.secretKey, ""),
+			MaxRetries:  aws.Int(0),
 		})
 	}
 
 	if parameters.iamRole != "" {
-		creds := assumeRoleCredentials(sess, parameters.iamRole, parameters.iamSession)
+		creds := assumeRoleCredentials(sess, parameters.iamRole, parameters.iamSession, parameters.externalID)
 		sess.Config.Credentials = creds
 	}
 
 	return sess, nil
 }
 
-func assumeRoleCredentials(sess *session.Session, iamRole, iamSession string) *credentials.Credentials {
+func assumeRoleCredentials(sess *session.Session, iamRole, iamSession, externalID string) *credentials.Credentials {
 
 	if iamSession == "" {
 		iamSession = "default"
@@ -52,6 +55,9 @@ func assumeRoleCredentials(sess *session.Session, iamRole, iamSession string) *c
 		o.Duration = time.Hour
 		o.ExpiryWindow = 5 * time.Minute
 		o.RoleSessionName = iamSession
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
 	})
 	return creds
 }
@@ -0,0 +1,132 @@
+// +build !test
+
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+)
+
+const (
+	sqsServiceName = "sqs"
+	sqsAPIVersion  = "2012-11-05"
+	// sqsWaitTimeSeconds is the long polling time of ReceiveMessages
+	sqsWaitTimeSeconds = 20
+	// sqsMaxNumberOfMessages is the maximum number of messages returned by ReceiveMessage
+	sqsMaxNumberOfMessages = 10
+)
+
+// SQSQueue receives the lifecycle notifications from an SQS queue, using the SQS query API
+type SQSQueue struct {
+	client   *client.Client
+	queueURL string
+}
+
+type sqsReceiveMessageInput struct {
+	_ struct{} `type:"structure"`
+
+	QueueUrl            *string `type:"string" required:"true"`
+	MaxNumberOfMessages *int64  `type:"integer"`
+	WaitTimeSeconds     *int64  `type:"integer"`
+}
+
+type sqsReceiveMessageOutput struct {
+	_ struct{} `type:"structure"`
+
+	Messages []*sqsMessage `locationNameList:"Message" type:"list" flattened:"true"`
+}
+
+type sqsMessage struct {
+	_ struct{} `type:"structure"`
+
+	MessageId     *string `type:"string"`
+	ReceiptHandle *string `type:"string"`
+	Body          *string `type:"string"`
+}
+
+type sqsDeleteMessageInput struct {
+	_ struct{} `type:"structure"`
+
+	QueueUrl      *string `type:"string" required:"true"`
+	ReceiptHandle *string `type:"string" required:"true"`
+}
+
+type sqsDeleteMessageOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// NewSQSQueue returns a new aws.SQSQueue for the queue URL
+func NewSQSQueue(accessKey, secretKey, region, iamRole, iamSession, queueURL string) (*SQSQueue, error) {
+
+	session, err := newAwsSession(&sessionParameters{
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		region:     region,
+		iamRole:    iamRole,
+		iamSession: iamSession,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	config := session.ClientConfig(sqsServiceName)
+	sqsClient := client.New(
+		*config.Config,
+		metadata.ClientInfo{
+			ServiceName:   sqsServiceName,
+			SigningRegion: config.SigningRegion,
+			Endpoint:      config.Endpoint,
+			APIVersion:    sqsAPIVersion,
+		},
+		config.Handlers,
+	)
+	sqsClient.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	sqsClient.Handlers.Build.PushBackNamed(query.BuildHandler)
+	sqsClient.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	sqsClient.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	sqsClient.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+
+	return &SQSQueue{client: sqsClient, queueURL: queueURL}, nil
+}
+
+// ReceiveMessages waits up to sqsWaitTimeSeconds for messages on the queue
+func (q *SQSQueue) ReceiveMessages() ([]*QueueMessage, error) {
+
+	input := &sqsReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: aws.Int64(sqsMaxNumberOfMessages),
+		WaitTimeSeconds:     aws.Int64(sqsWaitTimeSeconds),
+	}
+	output := &sqsReceiveMessageOutput{}
+
+	operation := &request.Operation{Name: "ReceiveMessage", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := q.client.NewRequest(operation, input, output).Send(); err != nil {
+		return nil, err
+	}
+
+	messages := []*QueueMessage{}
+	for _, message := range output.Messages {
+		messages = append(messages, &QueueMessage{
+			ID:            aws.StringValue(message.MessageId),
+			Body:          aws.StringValue(message.Body),
+			ReceiptHandle: aws.StringValue(message.ReceiptHandle),
+		})
+	}
+	return messages, nil
+}
+
+// DeleteMessage deletes a received message from the queue
+func (q *SQSQueue) DeleteMessage(receiptHandle string) error {
+
+	input := &sqsDeleteMessageInput{
+		QueueUrl:      aws.String(q.queueURL),
+		ReceiptHandle: aws.String(receiptHandle),
+	}
+
+	operation := &request.Operation{Name: "DeleteMessage", HTTPMethod: "POST", HTTPPath: "/"}
+	return q.client.NewRequest(operation, input, &sqsDeleteMessageOutput{}).Send()
+}
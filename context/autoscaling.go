@@ -0,0 +1,316 @@
+package context
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alanbover/deathnode/aws"
+)
+
+// Actions taken on the instances whose drain failed
+const (
+	// DrainFailureHeartbeat keeps the lifecycle action alive, so the instance waits until its tasks finish
+	DrainFailureHeartbeat = "heartbeat"
+	// DrainFailureAbandon completes the lifecycle action with ABANDON
+	DrainFailureAbandon = "abandon"
+	// DrainFailureStandby abandons the removal, moving the instance to Standby to keep it running. The scale-in
+	// protection is only removed once drained, so the instance is still InService when its drain fails
+	DrainFailureStandby = "standby"
+)
+
+// Ways to destroy the unhealthy instances terminated outside deathnode
+const (
+	// UnhealthyDrain drains them as any other instance, for up to UnhealthyDrainTimeout seconds
+	UnhealthyDrain = "drain"
+	// UnhealthyComplete completes their lifecycle action without waiting for their tasks
+	UnhealthyComplete = "complete"
+)
+
+// Ways to remove the instances of an autoscaling group
+const (
+	// RemovalLifecycle removes the scale-in protection of the instance and completes the lifecycle action of
+	// the deathnode lifecycle hook once AWS moves it to Terminating:Wait
+	RemovalLifecycle = "lifecycle"
+	// RemovalDetach detaches the instance from its autoscaling group and terminates it, without lifecycle hooks
+	RemovalDetach = "detach"
+)
+
+// AutoscalingGroupConf stores the settings for the autoscaling groups matched by a prefix or by a regexp,
+// and/or by tags. Settings left empty fall back to the global ones from ApplicationConf
+type AutoscalingGroupConf struct {
+	Prefix                 string            `json:"prefix"`
+	Regexp                 string            `json:"regexp"`
+	Tags                   map[string]string `json:"tags"`
+	Region                 string            `json:"region"`
+	RoleARN                string            `json:"roleArn"`
+	ExternalID             string            `json:"externalId"`
+	ConstraintsType        []string          `json:"constraintsType"`
+	RecommenderType        string            `json:"recommenderType"`
+	ProtectedFrameworks    []string          `json:"protectedFrameworks"`
+	ProtectedTasksLabels   []string          `json:"protectedTaskLabels"`
+	DelayDeleteSeconds     *int              `json:"delayDelete"`
+	LifecycleTimeout       *int              `json:"lifecycleTimeout"`
+	LaunchTimeout          *int              `json:"launchTimeout"`
+	LifecycleDefaultResult string            `json:"lifecycleDefaultResult"`
+	DrainTimeout           *int              `json:"drainTimeout"`
+	DrainFailurePolicy     string            `json:"drainFailurePolicy"`
+	EscalationAlert        *int              `json:"escalationAlert"`
+	EscalationForceDrain   *int              `json:"escalationForceDrain"`
+	EscalationAbandon      *int              `json:"escalationAbandon"`
+	UnhealthyPolicy        string            `json:"unhealthyPolicy"`
+	UnhealthyDrainTimeout  *int              `json:"unhealthyDrainTimeout"`
+	RemovalMode            string            `json:"removalMode"`
+	compiledRegexp         *regexp.Regexp
+}
+
+// AWSTarget identifies the AWS region and account where an autoscaling group lives. Empty fields fall back
+// to the region and credentials passed as flags
+type AWSTarget struct {
+	Region     string
+	RoleARN    string
+	ExternalID string
+}
+
+// IsDefault is true when the target is the one from the flags
+func (t AWSTarget) IsDefault() bool {
+	return t == AWSTarget{}
+}
+
+func (t AWSTarget) String() string {
+
+	parts := []string{}
+	for _, part := range []string{t.Region, t.RoleARN} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// AutoscalingGroupSettings stores the effective settings for an autoscaling group, once the global
+// settings have been overridden by its AutoscalingGroupConf
+type AutoscalingGroupSettings struct {
+	ConstraintsType      []string
+	RecommenderType      string
+	ProtectedFrameworks  []string
+	ProtectedTasksLabels []string
+	DelayDeleteSeconds   int
+	LifecycleTimeout     int
+	LaunchTimeout        int
+	// LifecycleDefaultResult is the result AWS applies to the deathnode lifecycle hook on timeout
+	LifecycleDefaultResult string
+	// DrainTimeout is the number of seconds after being marked before a drain is declared failed. 0 disables it
+	DrainTimeout int
+	// DrainFailurePolicy is the action taken on the instances whose drain failed
+	DrainFailurePolicy string
+	// EscalationAlert, EscalationForceDrain and EscalationAbandon are the number of seconds before the lifecycle
+	// action global timeout to alert, force the destroy and complete it with ABANDON. 0 disables each of them
+	EscalationAlert      int
+	EscalationForceDrain int
+	EscalationAbandon    int
+	// UnhealthyPolicy is how the instances terminated outside deathnode while unhealthy are destroyed
+	UnhealthyPolicy string
+	// UnhealthyDrainTimeout is the number of seconds unhealthy instances are drained with the drain policy
+	UnhealthyDrainTimeout int
+	// RemovalMode is how the drained instances are removed from the autoscaling group
+	RemovalMode string
+}
+
+// ID returns the identifier of the autoscaling group selector
+func (g *AutoscalingGroupConf) ID() string {
+
+	id := g.Prefix
+	if g.Regexp != "" {
+		id = "regexp:" + g.Regexp
+	}
+
+	if len(g.Tags) > 0 {
+		tags := []string{}
+		for key, value := range g.Tags {
+			tags = append(tags, key+"="+value)
+		}
+		sort.Strings(tags)
+		if id != "" {
+			id += ";"
+		}
+		id += "tags:" + strings.Join(tags, ",")
+	}
+
+	if target := g.Target(); !target.IsDefault() {
+		id += "@" + target.String()
+	}
+	return id
+}
+
+// Target returns the AWS region and account of the autoscaling groups matched by the selector
+func (g *AutoscalingGroupConf) Target() AWSTarget {
+	return AWSTarget{Region: g.Region, RoleARN: g.RoleARN, ExternalID: g.ExternalID}
+}
+
+// Validate checks that the selector has a prefix, a valid regexp or tags. Prefix and regexp can't be
+// used together, but any of them can be combined with tags
+func (g *AutoscalingGroupConf) Validate() error {
+
+	if g.Prefix != "" && g.Regexp != "" {
+		return fmt.Errorf("Autoscaling group selector can't have both a prefix and a regexp")
+	}
+	if g.Prefix == "" && g.Regexp == "" && len(g.Tags) == 0 {
+		return fmt.Errorf("Autoscaling group selector must have a prefix, a regexp or tags")
+	}
+	if g.ExternalID != "" && g.RoleARN == "" {
+		return fmt.Errorf("Autoscaling group selector %s has an externalId but no roleArn", g.ID())
+	}
+	for key := range g.Tags {
+		if key == "" {
+			return fmt.Errorf("Autoscaling group selector tags can't have an empty key")
+		}
+	}
+
+	if g.Regexp != "" {
+		compiledRegexp, err := regexp.Compile(g.Regexp)
+		if err != nil {
+			return fmt.Errorf("Invalid regexp %s for autoscaling group selector: %s", g.Regexp, err)
+		}
+		g.compiledRegexp = compiledRegexp
+	}
+	return nil
+}
+
+// Matches returns true if the autoscaling group, given its name and tags, is selected by this
+// AutoscalingGroupConf
+func (g *AutoscalingGroupConf) Matches(autoscalingGroupName string, tags map[string]string) bool {
+
+	for key, value := range g.Tags {
+		if tagValue, ok := tags[key]; !ok || tagValue != value {
+			return false
+		}
+	}
+	return g.matchesName(autoscalingGroupName)
+}
+
+func (g *AutoscalingGroupConf) matchesName(autoscalingGroupName string) bool {
+
+	if g.Regexp != "" {
+		if g.compiledRegexp == nil {
+			matched, _ := regexp.MatchString(g.Regexp, autoscalingGroupName)
+			return matched
+		}
+		return g.compiledRegexp.MatchString(autoscalingGroupName)
+	}
+	return strings.HasPrefix(autoscalingGroupName, g.Prefix)
+}
+
+// Selectors returns the autoscaling group selectors to monitor: the ones from the configuration
+// file first, followed by the autoscalingGroupName prefixes not already present on it
+func (c *ApplicationConf) Selectors() []*AutoscalingGroupConf {
+
+	selectors := []*AutoscalingGroupConf{}
+	ids := map[string]bool{}
+	for _, group := range c.AutoscalingGroups {
+		selectors = append(selectors, group)
+		ids[group.ID()] = true
+	}
+
+	for _, prefix := range c.AutoscalingGroupPrefixes {
+		if !ids[prefix] {
+			selectors = append(selectors, &AutoscalingGroupConf{Prefix: prefix})
+			ids[prefix] = true
+		}
+	}
+
+	return selectors
+}
+
+// Settings returns the effective settings for an autoscaling group selector. A nil selector
+// returns the global settings
+func (c *ApplicationConf) Settings(group *AutoscalingGroupConf) AutoscalingGroupSettings {
+
+	settings := AutoscalingGroupSettings{
+		ConstraintsType:        c.ConstraintsType,
+		RecommenderType:        c.RecommenderType,
+		ProtectedFrameworks:    c.ProtectedFrameworks,
+		ProtectedTasksLabels:   c.ProtectedTasksLabels,
+		DelayDeleteSeconds:     c.DelayDeleteSeconds,
+		LifecycleTimeout:       c.LifecycleTimeout,
+		LaunchTimeout:          c.LaunchTimeout,
+		LifecycleDefaultResult: c.LifecycleDefaultResult,
+		DrainTimeout:           c.DrainTimeout,
+		DrainFailurePolicy:     c.DrainFailurePolicy,
+		EscalationAlert:        c.EscalationAlert,
+		EscalationForceDrain:   c.EscalationForceDrain,
+		EscalationAbandon:      c.EscalationAbandon,
+		UnhealthyPolicy:        c.UnhealthyPolicy,
+		UnhealthyDrainTimeout:  c.UnhealthyDrainTimeout,
+		RemovalMode:            c.RemovalMode,
+	}
+
+	if settings.LifecycleDefaultResult == "" {
+		settings.LifecycleDefaultResult = aws.LifecycleActionContinue
+	}
+	if settings.DrainFailurePolicy == "" {
+		settings.DrainFailurePolicy = DrainFailureHeartbeat
+	}
+	if settings.UnhealthyPolicy == "" {
+		settings.UnhealthyPolicy = UnhealthyDrain
+	}
+	if settings.RemovalMode == "" {
+		settings.RemovalMode = RemovalLifecycle
+	}
+
+	if group == nil {
+		return settings
+	}
+
+	if len(group.ConstraintsType) > 0 {
+		settings.ConstraintsType = group.ConstraintsType
+	}
+	if group.RecommenderType != "" {
+		settings.RecommenderType = group.RecommenderType
+	}
+	if len(group.ProtectedFrameworks) > 0 {
+		settings.ProtectedFrameworks = group.ProtectedFrameworks
+	}
+	if len(group.ProtectedTasksLabels) > 0 {
+		settings.ProtectedTasksLabels = group.ProtectedTasksLabels
+	}
+	if group.DelayDeleteSeconds != nil {
+		settings.DelayDeleteSeconds = *group.DelayDeleteSeconds
+	}
+	if group.LifecycleTimeout != nil {
+		settings.LifecycleTimeout = *group.LifecycleTimeout
+	}
+	if group.LaunchTimeout != nil {
+		settings.LaunchTimeout = *group.LaunchTimeout
+	}
+	if group.LifecycleDefaultResult != "" {
+		settings.LifecycleDefaultResult = group.LifecycleDefaultResult
+	}
+	if group.DrainTimeout != nil {
+		settings.DrainTimeout = *group.DrainTimeout
+	}
+	if group.DrainFailurePolicy != "" {
+		settings.DrainFailurePolicy = group.DrainFailurePolicy
+	}
+	if group.EscalationAlert != nil {
+		settings.EscalationAlert = *group.EscalationAlert
+	}
+	if group.EscalationForceDrain != nil {
+		settings.EscalationForceDrain = *group.EscalationForceDrain
+	}
+	if group.EscalationAbandon != nil {
+		settings.EscalationAbandon = *group.EscalationAbandon
+	}
+	if group.UnhealthyPolicy != "" {
+		settings.UnhealthyPolicy = group.UnhealthyPolicy
+	}
+	if group.UnhealthyDrainTimeout != nil {
+		settings.UnhealthyDrainTimeout = *group.UnhealthyDrainTimeout
+	}
+	if group.RemovalMode != "" {
+		settings.RemovalMode = group.RemovalMode
+	}
+
+	return settings
+}
@@ -0,0 +1,117 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/events"
+)
+
+// maxLaunchTimeout is the maximum launchTimeout, so the heartbeat timeout of the launch lifecycle hook stays
+// under the 7200 seconds allowed by AWS
+const maxLaunchTimeout = 3600
+
+// configFile is the content of the JSON configuration file
+type configFile struct {
+	AutoscalingGroups []*AutoscalingGroupConf `json:"autoscalingGroups"`
+	Webhooks          []events.WebhookConf    `json:"webhooks"`
+}
+
+// LoadConfigFile reads the JSON configuration file and stores its autoscaling groups and webhooks in conf
+func LoadConfigFile(path string, conf *ApplicationConf) error {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read config file %s: %s", path, err)
+	}
+
+	var file configFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("Unable to parse config file %s: %s", path, err)
+	}
+
+	ids := map[string]bool{}
+	for _, group := range file.AutoscalingGroups {
+		if err := group.Validate(); err != nil {
+			return err
+		}
+		if ids[group.ID()] {
+			return fmt.Errorf("Autoscaling group selector %s is defined more than once", group.ID())
+		}
+		ids[group.ID()] = true
+	}
+
+	for i := range file.Webhooks {
+		if err := file.Webhooks[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	conf.AutoscalingGroups = file.AutoscalingGroups
+	conf.Webhooks = file.Webhooks
+	return nil
+}
+
+// Validate checks that there is at least one autoscaling group to monitor, and that all of them
+// have protected frameworks and constraints configured
+func (c *ApplicationConf) Validate() error {
+
+	selectors := c.Selectors()
+	if len(selectors) < 1 {
+		return fmt.Errorf("at least one autoscalingGroupName flag or autoscaling group in configFile is required")
+	}
+
+	for _, selector := range selectors {
+		settings := c.Settings(selector)
+
+		if len(settings.ProtectedFrameworks) < 1 {
+			return fmt.Errorf("at least one protectedFrameworks flag is required (or set for autoscaling group %s in configFile)",
+				selector.ID())
+		}
+
+		if len(settings.ConstraintsType) < 1 {
+			return fmt.Errorf("at least one constraintsType flag is required (or set for autoscaling group %s in configFile)",
+				selector.ID())
+		}
+
+		if settings.LaunchTimeout < 0 || settings.LaunchTimeout > maxLaunchTimeout {
+			return fmt.Errorf("launchTimeout must be between 0 and %d seconds (autoscaling group %s)",
+				maxLaunchTimeout, selector.ID())
+		}
+
+		if settings.LifecycleDefaultResult != aws.LifecycleActionContinue &&
+			settings.LifecycleDefaultResult != aws.LifecycleActionAbandon {
+			return fmt.Errorf("invalid lifecycleDefaultResult %s for autoscaling group %s: must be %s or %s",
+				settings.LifecycleDefaultResult, selector.ID(), aws.LifecycleActionContinue, aws.LifecycleActionAbandon)
+		}
+
+		if settings.DrainFailurePolicy != DrainFailureHeartbeat && settings.DrainFailurePolicy != DrainFailureAbandon &&
+			settings.DrainFailurePolicy != DrainFailureStandby {
+			return fmt.Errorf("invalid drainFailurePolicy %s for autoscaling group %s: must be %s, %s or %s",
+				settings.DrainFailurePolicy, selector.ID(), DrainFailureHeartbeat, DrainFailureAbandon, DrainFailureStandby)
+		}
+
+		if settings.UnhealthyPolicy != UnhealthyDrain && settings.UnhealthyPolicy != UnhealthyComplete {
+			return fmt.Errorf("invalid unhealthyPolicy %s for autoscaling group %s: must be %s or %s",
+				settings.UnhealthyPolicy, selector.ID(), UnhealthyDrain, UnhealthyComplete)
+		}
+
+		if settings.RemovalMode != RemovalLifecycle && settings.RemovalMode != RemovalDetach {
+			return fmt.Errorf("invalid removalMode %s for autoscaling group %s: must be %s or %s",
+				settings.RemovalMode, selector.ID(), RemovalLifecycle, RemovalDetach)
+		}
+
+		if settings.RemovalMode == RemovalDetach && settings.LaunchTimeout > 0 {
+			return fmt.Errorf("launchTimeout needs a lifecycle hook, so it can't be used with removalMode %s (autoscaling group %s)",
+				RemovalDetach, selector.ID())
+		}
+
+		if settings.EscalationAlert < 0 || settings.EscalationForceDrain < 0 || settings.EscalationAbandon < 0 {
+			return fmt.Errorf("escalation seconds can't be negative (autoscaling group %s)", selector.ID())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package context
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+
+	Convey("When loading a config file", t, func() {
+		conf := ApplicationConf{
+			ConstraintsType:          []string{"noConstraint"},
+			RecommenderType:          "firstAvailableAgent",
+			AutoscalingGroupPrefixes: []string{"mesos-agents-batch", "mesos-agents-default"},
+			ProtectedFrameworks:      []string{"marathon"},
+			DelayDeleteSeconds:       300,
+			LifecycleTimeout:         3600,
+		}
+
+		Convey("it should fail if the file contains an invalid regexp", func() {
+			So(LoadConfigFile("testdata/invalid_regexp.json", &conf), ShouldNotBeNil)
+		})
+		Convey("it should fail if the file doesn't exist", func() {
+			So(LoadConfigFile("testdata/doesntexist.json", &conf), ShouldNotBeNil)
+		})
+		Convey("if it is valid", func() {
+			So(LoadConfigFile("testdata/config.json", &conf), ShouldBeNil)
+			selectors := conf.Selectors()
+
+			Convey("flag prefixes not in the file should be added after the file ones", func() {
+				So(selectors, ShouldHaveLength, 3)
+				So(selectors[0].ID(), ShouldEqual, "mesos-agents-batch")
+				So(selectors[1].ID(), ShouldEqual, "regexp:^mesos-agents-(web|api)-[0-9]+$")
+				So(selectors[2].ID(), ShouldEqual, "mesos-agents-default")
+			})
+			Convey("selectors should match autoscaling groups by prefix or regexp", func() {
+				So(selectors[0].Matches("mesos-agents-batch-v2", nil), ShouldBeTrue)
+				So(selectors[1].Matches("mesos-agents-web-12", nil), ShouldBeTrue)
+				So(selectors[1].Matches("mesos-agents-web-canary", nil), ShouldBeFalse)
+			})
+			Convey("settings set on the file should override the global ones", func() {
+				settings := conf.Settings(selectors[0])
+				So(settings.ConstraintsType, ShouldResemble, []string{"protectedConstraint"})
+				So(settings.RecommenderType, ShouldEqual, "smallestInstanceId")
+				So(settings.ProtectedFrameworks, ShouldResemble, []string{"spark"})
+				So(settings.DelayDeleteSeconds, ShouldEqual, 0)
+				So(settings.LifecycleTimeout, ShouldEqual, 7200)
+			})
+			Convey("settings not set on the file should fall back to the global ones", func() {
+				settings := conf.Settings(selectors[1])
+				So(settings.ConstraintsType, ShouldResemble, []string{"noConstraint"})
+				So(settings.ProtectedFrameworks, ShouldResemble, []string{"marathon"})
+				So(settings.ProtectedTasksLabels, ShouldResemble, []string{"DEATHNODE_PROTECTED"})
+				So(settings.DelayDeleteSeconds, ShouldEqual, 300)
+				So(conf.Settings(selectors[2]), ShouldResemble, conf.Settings(nil))
+			})
+			Convey("selectors with tags should also match autoscaling groups by their tags", func() {
+				selector := &AutoscalingGroupConf{Prefix: "mesos-agents", Tags: map[string]string{
+					"mesos-cluster": "prod", "deathnode:managed": "true"}}
+				So(selector.Validate(), ShouldBeNil)
+				So(selector.ID(), ShouldEqual, "mesos-agents;tags:deathnode:managed=true,mesos-cluster=prod")
+				So(selector.Matches("mesos-agents-web", map[string]string{
+					"mesos-cluster": "prod", "deathnode:managed": "true", "team": "web"}), ShouldBeTrue)
+				So(selector.Matches("mesos-agents-web", map[string]string{"mesos-cluster": "prod"}), ShouldBeFalse)
+				So(selector.Matches("other-agents", map[string]string{
+					"mesos-cluster": "prod", "deathnode:managed": "true"}), ShouldBeFalse)
+				So((&AutoscalingGroupConf{Prefix: "a", Regexp: "^a"}).Validate(), ShouldNotBeNil)
+				So((&AutoscalingGroupConf{}).Validate(), ShouldNotBeNil)
+			})
+			Convey("webhooks should be loaded with their defaults set", func() {
+				So(conf.Webhooks, ShouldHaveLength, 1)
+				So(conf.Webhooks[0].Name, ShouldEqual, "hooks.example.com")
+				So(conf.Webhooks[0].Events, ShouldResemble, []string{"drain_failed", "deadline_alert"})
+				So(*conf.Webhooks[0].Retries, ShouldEqual, 3)
+			})
+		})
+	})
+}
@@ -1,8 +1,12 @@
 package context
 
 import (
+	"sort"
+	"sync"
+
 	"github.com/alanbover/deathnode/aurora"
 	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/events"
 	"github.com/alanbover/deathnode/mesos"
 	"github.com/benbjohnson/clock"
 )
@@ -17,18 +21,103 @@ type ApplicationConf struct {
 	ProtectedTasksLabels     arrayFlags
 	DelayDeleteSeconds       int
 	LifecycleTimeout         int
+	LaunchTimeout            int
+	LifecycleDefaultResult   string
+	DrainTimeout             int
+	DrainFailurePolicy       string
+	EscalationAlert          int
+	EscalationForceDrain     int
+	EscalationAbandon        int
+	UnhealthyPolicy          string
+	UnhealthyDrainTimeout    int
+	RemovalMode              string
+	InstanceRefreshSeconds   int
 	ResetLifecycle           bool
 	AuroraURL                string
 	ForceLifeCycleHook       bool
+	DryRun                   bool
+	AutoscalingGroups        []*AutoscalingGroupConf
+	Webhooks                 []events.WebhookConf
 }
 
-// ApplicationContext stores the application configurations and both AWS and Mesos connections
+// ApplicationContext stores the application configurations, the AWS, Mesos and Aurora connections and
+// the sink for the emitted events. AwsConn is used for the autoscaling groups on the default target, while
+// AwsConnFactory creates the connections for the rest of targets
 type ApplicationContext struct {
-	Conf       ApplicationConf
-	AwsConn    aws.ClientInterface
-	MesosConn  mesos.ClientInterface
-	AuroraConn aurora.ClientInterface
-	Clock      clock.Clock
+	Conf           ApplicationConf
+	AwsConn        aws.ClientInterface
+	AwsConnFactory func(target AWSTarget) (aws.ClientInterface, error)
+	MesosConn      mesos.ClientInterface
+	AuroraConn     aurora.ClientInterface
+	Clock          clock.Clock
+	Events         events.Sink
+	Paused         func() bool
+	awsConnsMutex  sync.Mutex
+	awsConns       map[AWSTarget]aws.ClientInterface
+}
+
+// IsPaused is true when removals are paused globally. Without a Paused function they never are
+func (c *ApplicationContext) IsPaused() bool {
+	return c.Paused != nil && c.Paused()
+}
+
+// AwsConnFor returns the AWS connection for a target, creating it on first use. The default target, or
+// any target when there is no AwsConnFactory, uses AwsConn
+func (c *ApplicationContext) AwsConnFor(target AWSTarget) (aws.ClientInterface, error) {
+
+	if target.IsDefault() || c.AwsConnFactory == nil {
+		return c.AwsConn, nil
+	}
+
+	c.awsConnsMutex.Lock()
+	defer c.awsConnsMutex.Unlock()
+
+	if awsConn, ok := c.awsConns[target]; ok {
+		return awsConn, nil
+	}
+
+	awsConn, err := c.AwsConnFactory(target)
+	if err != nil {
+		return nil, err
+	}
+	if c.awsConns == nil {
+		c.awsConns = map[AWSTarget]aws.ClientInterface{}
+	}
+	c.awsConns[target] = awsConn
+	return awsConn, nil
+}
+
+// AwsConns returns AwsConn followed by the connections created for other targets, sorted by target
+func (c *ApplicationContext) AwsConns() []aws.ClientInterface {
+
+	c.awsConnsMutex.Lock()
+	defer c.awsConnsMutex.Unlock()
+
+	targets := []AWSTarget{}
+	for target := range c.awsConns {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].String()+targets[i].ExternalID < targets[j].String()+targets[j].ExternalID
+	})
+
+	awsConns := []aws.ClientInterface{c.AwsConn}
+	for _, target := range targets {
+		awsConns = append(awsConns, c.awsConns[target])
+	}
+	return awsConns
+}
+
+// Emit sends an event to the configured events sink, if any, timestamping it with the application clock
+func (c *ApplicationContext) Emit(event events.Event) {
+
+	if c.Events == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = c.Clock.Now()
+	}
+	c.Events.Emit(event)
 }
 
 type arrayFlags []string
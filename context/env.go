@@ -0,0 +1,87 @@
+package context
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables that configure deathnode flags
+	EnvPrefix = "DEATHNODE_"
+	// EnvListSeparator separates the values of repeatable flags set from an environment variable
+	EnvListSeparator = ","
+
+	// SourceFlag means a setting value was set on the command line
+	SourceFlag = "flag"
+	// SourceEnv means a setting value was set from an environment variable
+	SourceEnv = "env"
+	// SourceDefault means a setting value is the flag default
+	SourceDefault = "default"
+)
+
+// EnvName returns the environment variable for a flag name, i.e: autoscalingGroupName is
+// configured by DEATHNODE_AUTOSCALING_GROUP_NAME
+func EnvName(flagName string) string {
+
+	envName := []rune{}
+	for i, r := range flagName {
+		if unicode.IsUpper(r) && i > 0 {
+			envName = append(envName, '_')
+		}
+		envName = append(envName, unicode.ToUpper(r))
+	}
+
+	return EnvPrefix + string(envName)
+}
+
+// ApplyEnv sets every flag not given on the command line from its environment variable, if present.
+// Repeatable flags take a list of values split by EnvListSeparator. It returns the source of every
+// flag value, so precedence is flag > env > default
+func ApplyEnv(flags *flag.FlagSet, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+
+	sources := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		sources[f.Name] = SourceFlag
+	})
+
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || sources[f.Name] == SourceFlag {
+			return
+		}
+
+		value, ok := lookupEnv(EnvName(f.Name))
+		if !ok {
+			sources[f.Name] = SourceDefault
+			return
+		}
+
+		values := []string{value}
+		if _, isList := f.Value.(*arrayFlags); isList {
+			values = splitEnvList(value)
+		}
+
+		for _, value := range values {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("Invalid value %q for %s: %s", value, EnvName(f.Name), setErr)
+				return
+			}
+		}
+		sources[f.Name] = SourceEnv
+	})
+
+	return sources, err
+}
+
+func splitEnvList(value string) []string {
+
+	values := []string{}
+	for _, item := range strings.Split(value, EnvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
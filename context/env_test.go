@@ -0,0 +1,77 @@
+package context
+
+import (
+	"flag"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnvName(t *testing.T) {
+
+	Convey("Environment variable names should be the prefixed flag name in upper snake case", t, func() {
+		So(EnvName("autoscalingGroupName"), ShouldEqual, "DEATHNODE_AUTOSCALING_GROUP_NAME")
+		So(EnvName("mesosUrl"), ShouldEqual, "DEATHNODE_MESOS_URL")
+		So(EnvName("debug"), ShouldEqual, "DEATHNODE_DEBUG")
+	})
+}
+
+func TestApplyEnv(t *testing.T) {
+
+	Convey("When applying the environment to the flags", t, func() {
+		var conf ApplicationConf
+		var mesosURL string
+		var debug bool
+		flags := flag.NewFlagSet("deathnode", flag.ContinueOnError)
+		flags.StringVar(&mesosURL, "mesosUrl", "", "")
+		flags.BoolVar(&debug, "debug", false, "")
+		flags.StringVar(&conf.RecommenderType, "recommenderType", "firstAvailableAgent", "")
+		flags.Var(&conf.ProtectedFrameworks, "protectedFrameworks", "")
+		flags.Var(&conf.ConstraintsType, "constraintsType", "")
+
+		env := map[string]string{
+			"DEATHNODE_MESOS_URL":            "http://env.mesos",
+			"DEATHNODE_DEBUG":                "true",
+			"DEATHNODE_PROTECTED_FRAMEWORKS": "marathon, chronos,",
+			"DEATHNODE_CONSTRAINTS_TYPE":     "noConstraint",
+		}
+		lookupEnv := func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		}
+
+		flags.Parse([]string{"-mesosUrl", "http://flag.mesos", "-constraintsType", "protectedConstraint"})
+		sources, err := ApplyEnv(flags, lookupEnv)
+
+		Convey("it should not fail", func() {
+			So(err, ShouldBeNil)
+		})
+		Convey("flags given on the command line should take precedence", func() {
+			So(mesosURL, ShouldEqual, "http://flag.mesos")
+			So(sources["mesosUrl"], ShouldEqual, SourceFlag)
+			So(conf.ConstraintsType, ShouldHaveLength, 1)
+			So(conf.ConstraintsType[0], ShouldEqual, "protectedConstraint")
+		})
+		Convey("flags not given should be set from the environment", func() {
+			So(debug, ShouldBeTrue)
+			So(sources["debug"], ShouldEqual, SourceEnv)
+		})
+		Convey("repeatable flags should be split by the list separator", func() {
+			So(conf.ProtectedFrameworks, ShouldHaveLength, 2)
+			So(conf.ProtectedFrameworks[0], ShouldEqual, "marathon")
+			So(conf.ProtectedFrameworks[1], ShouldEqual, "chronos")
+		})
+		Convey("flags on neither should keep their default", func() {
+			So(conf.RecommenderType, ShouldEqual, "firstAvailableAgent")
+			So(sources["recommenderType"], ShouldEqual, SourceDefault)
+		})
+		Convey("invalid values should return an error", func() {
+			env["DEATHNODE_DEBUG"] = "notabool"
+			debug = false
+			flags = flag.NewFlagSet("deathnode", flag.ContinueOnError)
+			flags.BoolVar(&debug, "debug", false, "")
+			_, err := ApplyEnv(flags, lookupEnv)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package deathnode
+
+// Removal of instances detaching them from their autoscaling group and terminating them, without lifecycle hooks
+
+import (
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/monitor"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	log "github.com/sirupsen/logrus"
+)
+
+// detachedInstance is an instance detached by deathnode that is not part of its autoscaling group anymore, but
+// still running
+type detachedInstance struct {
+	instance *ec2.Instance
+	awsConn  aws.ClientInterface
+}
+
+// detachInstance removes the instance from its autoscaling group and terminates it, without lifecycle hooks.
+// Once detached, the instance is terminated from terminateDetachedInstances if this attempt fails.
+// The desired capacity is never decremented: the instances are scale-in protected, so AWS already lowered it
+// when the instances picked by the recommender became undesired, while manually marked ones get a replacement
+func (n *Notebook) detachInstance(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) error {
+
+	shouldDecrementDesiredCapacity := false
+	if !instanceMonitor.IsDetached() {
+		log.Infof("Detach instance %s from autoscaling group %s", *instanceMonitor.InstanceID(),
+			autoscalingMonitor.GetAutoscalingGroupName())
+		err := instanceMonitor.Detach(shouldDecrementDesiredCapacity)
+		instanceMonitor.EmitEvent(events.InstanceDetached, map[string]interface{}{
+			"shouldDecrementDesiredCapacity": shouldDecrementDesiredCapacity,
+		}, err)
+		if err != nil {
+			log.Errorf("Unable to detach instance %s", *instanceMonitor.InstanceID())
+			return err
+		}
+	}
+
+	log.Infof("Terminate instance %s", *instanceMonitor.InstanceID())
+	err := instanceMonitor.Terminate()
+	instanceMonitor.EmitEvent(events.InstanceTerminated, map[string]interface{}{
+		"markTimestamp": instanceMonitor.MarkTimestamp(),
+	}, err)
+	if err != nil {
+		log.Errorf("Unable to terminate instance %s", *instanceMonitor.InstanceID())
+		return err
+	}
+	n.recordDestroy(autoscalingMonitor, instanceMonitor)
+	if n.ctx.Conf.AuroraURL != "" {
+		return n.endMaintenance(instanceMonitor)
+	}
+	return nil
+}
+
+// detachedInstancesList returns the instances detached by deathnode still running
+func (n *Notebook) detachedInstancesList() []*ec2.Instance {
+
+	instances := []*ec2.Instance{}
+	for _, detached := range n.detachedInstances {
+		instances = append(instances, detached.instance)
+	}
+	return instances
+}
+
+// terminateDetachedInstances terminates the instances detached by deathnode that are not part of their
+// autoscaling group anymore, as the detach or terminate attempt that removed them from it failed. Their
+// maintenance only ends once they are terminated
+func (n *Notebook) terminateDetachedInstances() {
+
+	if n.pause.isPaused() {
+		return
+	}
+
+	for _, detached := range n.detachedInstances {
+		instance := detached.instance
+		log.Infof("Terminate detached instance %s", *instance.InstanceId)
+		err := detached.awsConn.TerminateInstance(instance.InstanceId)
+		n.emitInstanceEvent(instance, events.InstanceTerminated, nil, err)
+		if err != nil {
+			log.Errorf("Unable to terminate detached instance %s", *instance.InstanceId)
+			continue
+		}
+		if n.ctx.Conf.AuroraURL != "" {
+			if err := n.endHostMaintenance(*instance.InstanceId, *instance.PrivateIpAddress); err != nil {
+				log.Warn(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package deathnode
+
+// Escalation of the instances getting close to the AWS global timeout of their lifecycle action, after which
+// AWS applies the default result no matter how many heartbeats were recorded
+
+import (
+	"time"
+
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/monitor"
+	log "github.com/sirupsen/logrus"
+)
+
+// lifecycleDeadline returns when AWS applies the default result to the lifecycle action of the instance
+// regardless of heartbeats, and false if it's not in Terminating:Wait
+func (n *Notebook) lifecycleDeadline(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) (time.Time, bool) {
+
+	since := instanceMonitor.TerminatingWaitSince()
+	if instanceMonitor.LifecycleState() != monitor.LifecycleStateTerminatingWait || since.IsZero() {
+		return time.Time{}, false
+	}
+	return since.Add(monitor.LifecycleGlobalTimeout(autoscalingMonitor.Settings().LifecycleTimeout)), true
+}
+
+// lifecycleRemaining returns the time left until the lifecycle deadline of the instance, and false if it's
+// not in Terminating:Wait
+func (n *Notebook) lifecycleRemaining(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) (time.Duration, bool) {
+
+	deadline, ok := n.lifecycleDeadline(autoscalingMonitor, instanceMonitor)
+	if !ok {
+		return 0, false
+	}
+	return deadline.Sub(n.ctx.Clock.Now()), true
+}
+
+// isWithin is true when the remaining time is within the escalation seconds. 0 seconds disables it
+func isWithin(remaining time.Duration, seconds int) bool {
+	return seconds > 0 && remaining <= time.Duration(seconds)*time.Second
+}
+
+// alertLifecycleDeadline emits a deadline alert, once, for the instances within EscalationAlert seconds of
+// their lifecycle deadline. Alerts are raised even while removals are paused
+func (n *Notebook) alertLifecycleDeadline(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) {
+
+	remaining, ok := n.lifecycleRemaining(autoscalingMonitor, instanceMonitor)
+	if !ok || !isWithin(remaining, autoscalingMonitor.Settings().EscalationAlert) {
+		return
+	}
+
+	if instanceMonitor.SetDeadlineAlerted() {
+		deadline, _ := n.lifecycleDeadline(autoscalingMonitor, instanceMonitor)
+		log.Warnf("Instance %s has been on %s since %s. AWS will apply the lifecycle default result in %s",
+			*instanceMonitor.InstanceID(), monitor.LifecycleStateTerminatingWait,
+			instanceMonitor.TerminatingWaitSince(), remaining)
+		instanceMonitor.EmitEvent(events.DeadlineAlert, map[string]interface{}{
+			"deadline":         deadline.Unix(),
+			"remainingSeconds": int64(remaining.Seconds()),
+		}, nil)
+	}
+}
+
+// escalateLifecycleDeadline forces the destroy of the instances within EscalationForceDrain seconds of their
+// lifecycle deadline, and abandons the lifecycle action of the ones within EscalationAbandon seconds. It
+// returns true if the lifecycle action was abandoned
+func (n *Notebook) escalateLifecycleDeadline(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) (bool, error) {
+
+	remaining, ok := n.lifecycleRemaining(autoscalingMonitor, instanceMonitor)
+	if !ok {
+		return false, nil
+	}
+
+	settings := autoscalingMonitor.Settings()
+	if isWithin(remaining, settings.EscalationAbandon) {
+		return true, n.abandonLifecycleAction(autoscalingMonitor, instanceMonitor, "lifecycle deadline")
+	}
+
+	if isWithin(remaining, settings.EscalationForceDrain) && !instanceMonitor.IsForcedDestroy() {
+		log.Warnf("Forcing destroy of instance %s, %s before its lifecycle deadline",
+			*instanceMonitor.InstanceID(), remaining)
+		instanceMonitor.ForceDestroy()
+		instanceMonitor.EmitEvent(events.DestroyForced, map[string]interface{}{"reason": "lifecycle deadline"}, nil)
+	}
+	return false, nil
+}
@@ -0,0 +1,85 @@
+package deathnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/mesos"
+	"github.com/benbjohnson/clock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLifecycleDeadlineEscalation(t *testing.T) {
+
+	clockMock := clock.NewMock()
+	clockMock.Set(time.Unix(1190995200, 0))
+	// Marked at 1190995200 with a 3600 seconds heartbeat timeout, so AWS gives up after 48 hours
+	deadline := time.Unix(1190995200, 0).Add(48 * time.Hour)
+
+	Convey("When an instance in Terminating:Wait keeps running protected tasks", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node_with_tag", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"one_undesired_host", "one_undesired_host"},
+				"DescribeAGByName":       {"one_undesired_host_one_terminating"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clockMock)
+		notebook.ctx.Conf.ResetLifecycle = false
+		notebook.ctx.Conf.EscalationAlert = 3600
+		notebook.ctx.Conf.EscalationForceDrain = 1800
+		notebook.ctx.Conf.EscalationAbandon = 600
+		recorder := &events.Recorder{}
+		notebook.ctx.Events = recorder
+		autoscalingMonitor := notebook.autoscalingGroups.GetAutoscalingGroupMonitorsList()[0]
+		instanceMonitor, _ := notebook.autoscalingGroups.GetInstanceByID("i-34719eb8")
+		awsConn.FlushMock()
+
+		Convey("the remaining time should be counted from its mark", func() {
+			remaining, ok := notebook.lifecycleRemaining(autoscalingMonitor, instanceMonitor)
+			So(ok, ShouldBeTrue)
+			So(remaining, ShouldEqual, 48*time.Hour)
+		})
+		Convey("far from the deadline, nothing should be done", func() {
+			clockMock.Set(deadline.Add(-4000 * time.Second))
+			notebook.DestroyInstancesAttempt()
+			So(recorder.OfType(events.DeadlineAlert), ShouldBeEmpty)
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+		})
+		Convey("within escalationAlert, it should be alerted only once", func() {
+			clockMock.Set(deadline.Add(-3000 * time.Second))
+			notebook.DestroyInstancesAttempt()
+			notebook.DestroyInstancesAttempt()
+			So(recorder.OfType(events.DeadlineAlert), ShouldHaveLength, 1)
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+		})
+		Convey("within escalationForceDrain, it should be destroyed without waiting for its tasks", func() {
+			clockMock.Set(deadline.Add(-1000 * time.Second))
+			notebook.DestroyInstancesAttempt()
+			So(recorder.OfType(events.DestroyForced), ShouldHaveLength, 1)
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-34719eb8", "CONTINUE"}})
+		})
+		Convey("within escalationAbandon, its lifecycle action should be abandoned", func() {
+			clockMock.Set(deadline.Add(-300 * time.Second))
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-34719eb8", "ABANDON"}})
+			So(recorder.OfType(events.LifecycleAbandoned), ShouldHaveLength, 1)
+		})
+		Reset(func() {
+			clockMock.Set(time.Unix(1190995200, 0))
+		})
+	})
+}
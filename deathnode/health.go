@@ -0,0 +1,91 @@
+package deathnode
+
+// Tracks the results of the control loop, so liveness and readiness can be checked
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// ComponentAWS identifies the refresh of the autoscaling groups from AWS
+	ComponentAWS = "aws"
+	// ComponentMesos identifies the refresh of the Mesos cache
+	ComponentMesos = "mesos"
+	// ComponentAurora identifies the refresh of the Aurora maintenance cache
+	ComponentAurora = "aurora"
+
+	refreshOK = "ok"
+)
+
+// health stores when the last run completed and the result of the last refresh of every component
+type health struct {
+	mutex            sync.Mutex
+	lastRunCompleted time.Time
+	refreshResults   map[string]string
+}
+
+func (h *health) setRefreshResults(results map[string]error) {
+
+	refreshResults := map[string]string{}
+	for component, err := range results {
+		refreshResults[component] = refreshOK
+		if err != nil {
+			refreshResults[component] = err.Error()
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.refreshResults = refreshResults
+}
+
+// setRefreshResult updates the result of the last refresh of a single component
+func (h *health) setRefreshResult(component string, err error) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	refreshResults := map[string]string{}
+	for refreshedComponent, result := range h.refreshResults {
+		refreshResults[refreshedComponent] = result
+	}
+	refreshResults[component] = refreshOK
+	if err != nil {
+		refreshResults[component] = err.Error()
+	}
+	h.refreshResults = refreshResults
+}
+
+func (h *health) setRunCompleted(timestamp time.Time) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastRunCompleted = timestamp
+}
+
+// LastRunCompleted returns when the last run completed all its steps. Zero if none has completed yet
+func (y *Watcher) LastRunCompleted() time.Time {
+
+	y.health.mutex.Lock()
+	defer y.health.mutex.Unlock()
+	return y.health.lastRunCompleted
+}
+
+// Readiness returns the result of the last refresh of every component ("ok" or the error found), and
+// whether all of them succeeded. It's never ready before the first refresh
+func (y *Watcher) Readiness() (map[string]string, bool) {
+
+	y.health.mutex.Lock()
+	defer y.health.mutex.Unlock()
+
+	results := map[string]string{}
+	ready := len(y.health.refreshResults) > 0
+	for component, result := range y.health.refreshResults {
+		results[component] = result
+		if result != refreshOK {
+			ready = false
+		}
+	}
+	return results, ready
+}
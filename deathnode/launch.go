@@ -0,0 +1,61 @@
+package deathnode
+
+// Completion of the launch lifecycle actions, once the new instances have joined the Mesos cluster
+
+import (
+	"time"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/monitor"
+	log "github.com/sirupsen/logrus"
+)
+
+// completeLaunches lets the instances in Pending:Wait go InService once an active Mesos agent with their IP
+// has registered, and abandons the ones still not registered after the LaunchTimeout of their autoscaling
+// group. It must only be called after a successful Mesos refresh
+func (y *Watcher) completeLaunches() {
+
+	now := y.ctx.Clock.Now()
+	for _, autoscalingMonitor := range y.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList() {
+		launchTimeout := autoscalingMonitor.Settings().LaunchTimeout
+		if launchTimeout <= 0 {
+			continue
+		}
+
+		for _, instanceMonitor := range autoscalingMonitor.GetAllInstances() {
+			if instanceMonitor.LifecycleState() != monitor.LifecycleStatePendingWait ||
+				instanceMonitor.IsLaunchCompleted() {
+				continue
+			}
+			if instanceMonitor.LaunchTime().IsZero() {
+				instanceMonitor.SetLaunchTime(now)
+			}
+			y.completeLaunch(instanceMonitor, now, time.Duration(launchTimeout)*time.Second)
+		}
+	}
+}
+
+func (y *Watcher) completeLaunch(instanceMonitor *monitor.InstanceMonitor, now time.Time,
+	launchTimeout time.Duration) {
+
+	waited := now.Sub(instanceMonitor.LaunchTime())
+	result, eventType := aws.LifecycleActionContinue, events.LaunchCompleted
+	if !y.mesosMonitor.IsAgentActive(instanceMonitor.IP()) {
+		if waited < launchTimeout {
+			log.Debugf("Instance %s has no active Mesos agent yet. Waiting for it", *instanceMonitor.InstanceID())
+			return
+		}
+		result, eventType = aws.LifecycleActionAbandon, events.LaunchAbandoned
+		log.Warnf("No active Mesos agent found for instance %s after %s. Abandoning its launch",
+			*instanceMonitor.InstanceID(), waited)
+	} else {
+		log.Infof("Mesos agent of instance %s is active. Completing its launch", *instanceMonitor.InstanceID())
+	}
+
+	err := instanceMonitor.CompleteLaunch(result)
+	instanceMonitor.EmitEvent(eventType, map[string]interface{}{"waitedSeconds": int64(waited.Seconds())}, err)
+	if err != nil {
+		log.Errorf("Unable to complete launch lifecycle action of instance %s: %s", *instanceMonitor.InstanceID(), err)
+	}
+}
@@ -0,0 +1,49 @@
+package deathnode
+
+import (
+	"testing"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/mesos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompleteLaunches(t *testing.T) {
+
+	Convey("When instances are waiting on the launch lifecycle hook", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {"node1", "node2", "node_unregistered", "node_unregistered_old"},
+				"DescribeAGByName":     {"pending_launches"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"notasks"},
+			},
+		}
+		watcher := newWatcher(testCollectionValues{awsConn: awsConn, mesosConn: mesosConn})
+		watcher.ctx.Conf.LaunchTimeout = 300
+		watcher.autoscalingServiceMonitor.Refresh()
+		watcher.mesosMonitor.Refresh()
+
+		Convey("the launch lifecycle hook should be installed", func() {
+			So(awsConn.Requests["PutLaunchLifeCycleHook"], ShouldResemble, [][]string{{"some-Autoscaling-Group", "600"}})
+		})
+		Convey("registered agents should continue, and the ones over the timeout should be abandoned", func() {
+			watcher.completeLaunches()
+			So(awsConn.Requests["CompleteLaunchLifecycleAction"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-0c9d8e7f", "ABANDON"},
+				{"some-Autoscaling-Group", "i-446a73cf", "CONTINUE"},
+			})
+
+			Convey("and each launch should only be completed once", func() {
+				awsConn.FlushMock()
+				watcher.completeLaunches()
+				So(awsConn.Requests["CompleteLaunchLifecycleAction"], ShouldBeEmpty)
+			})
+		})
+	})
+}
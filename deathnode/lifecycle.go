@@ -0,0 +1,174 @@
+package deathnode
+
+// Reads the lifecycle notifications of the autoscaling groups from a queue, so instances are destroyed as soon
+// as they reach Terminating:Wait instead of on the next polling tick
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/events"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	log "github.com/sirupsen/logrus"
+)
+
+// LifecycleListener triggers a targeted run for every lifecycle notification received from a queue. Messages
+// are deleted once handled, so the failed ones are received again. The Loop keeps reconciling everything else
+type LifecycleListener struct {
+	queue      aws.QueueInterface
+	watcher    *Watcher
+	retryDelay time.Duration
+}
+
+// NewLifecycleListener returns a new LifecycleListener object. Failed receives are retried after retryDelay
+func NewLifecycleListener(queue aws.QueueInterface, watcher *Watcher, retryDelay time.Duration) *LifecycleListener {
+
+	return &LifecycleListener{
+		queue:      queue,
+		watcher:    watcher,
+		retryDelay: retryDelay,
+	}
+}
+
+// Start receives and handles messages until stop is closed
+func (l *LifecycleListener) Start(stop <-chan struct{}) {
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err := l.Poll(); err != nil {
+			log.Errorf("Unable to receive lifecycle notifications: %s", err)
+			l.watcher.ctx.Clock.Sleep(l.retryDelay)
+		}
+	}
+}
+
+// Poll receives a batch of messages from the queue and handles them
+func (l *LifecycleListener) Poll() error {
+
+	messages, err := l.queue.ReceiveMessages()
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := l.handle(message); err != nil {
+			log.Errorf("Unable to handle lifecycle notification %s: %s. It will be received again", message.ID, err)
+			continue
+		}
+		if err := l.queue.DeleteMessage(message.ReceiptHandle); err != nil {
+			log.Errorf("Unable to delete lifecycle notification %s: %s", message.ID, err)
+		}
+	}
+	return nil
+}
+
+func (l *LifecycleListener) handle(message *aws.QueueMessage) error {
+
+	event, err := aws.ParseLifecycleMessage(message.Body)
+	if err != nil {
+		// It would be received forever
+		log.Warnf("Discarding lifecycle notification %s: %s", message.ID, err)
+		return nil
+	}
+	if event == nil {
+		log.Debugf("Ignoring notification %s, as it's not a lifecycle action", message.ID)
+		return nil
+	}
+	return l.watcher.HandleLifecycleEvent(event)
+}
+
+// HandleLifecycleEvent refreshes the autoscaling group of the notified instance and, if it's marked to be
+// removed, attempts to destroy it with the lifecycle action token. Notifications for other transitions or
+// hooks, or for autoscaling groups not monitored, are ignored
+func (y *Watcher) HandleLifecycleEvent(event *aws.LifecycleEvent) error {
+
+	if event.LifecycleTransition != aws.LifecycleTransitionTerminating || event.LifecycleHookName != aws.LifecycleHookName {
+		log.Debugf("Ignoring lifecycle notification %s of hook %s for instance %s",
+			event.LifecycleTransition, event.LifecycleHookName, event.InstanceID)
+		return nil
+	}
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	autoscalingMonitor, err := y.autoscalingServiceMonitor.GetAutoscalingGroupMonitorByInstanceID(event.InstanceID)
+	if err != nil {
+		autoscalingMonitor, err = y.autoscalingServiceMonitor.GetAutoscalingGroupMonitor(event.AutoscalingGroupName)
+		if err != nil {
+			log.Debugf("Ignoring lifecycle notification for instance %s: %s", event.InstanceID, err)
+			return nil
+		}
+	}
+
+	if err := y.autoscalingServiceMonitor.RefreshAutoscalingGroupMonitor(autoscalingMonitor); err != nil {
+		return err
+	}
+
+	instanceMonitor, err := y.autoscalingServiceMonitor.GetInstanceByID(event.InstanceID)
+	if err != nil {
+		log.Debugf("Ignoring lifecycle notification for instance %s: %s", event.InstanceID, err)
+		return nil
+	}
+	instanceMonitor.SetLifecycleActionToken(event.LifecycleActionToken)
+	instanceMonitor.EmitEvent(events.LifecycleNotified, map[string]interface{}{
+		"transition": event.LifecycleTransition,
+	}, nil)
+
+	if !instanceMonitor.IsMarkedToBeRemoved() {
+		return nil
+	}
+
+	err = y.mesosMonitor.Refresh()
+	y.health.setRefreshResult(ComponentMesos, err)
+	if err != nil {
+		return err
+	}
+	if y.ctx.Conf.AuroraURL != "" {
+		err := y.auroraMonitor.Refresh()
+		y.health.setRefreshResult(ComponentAurora, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	instances, err := instanceMonitor.AwsConn().DescribeInstancesByIDs([]string{event.InstanceID})
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("No instance information found for instance id %v", event.InstanceID)
+	}
+
+	if err := y.scheduleMaintenance(instances[0]); err != nil {
+		return err
+	}
+	return y.notebook.destroyInstanceAttempt(instances[0])
+}
+
+// scheduleMaintenance puts the instance in maintenance before it's destroyed, as DestroyInstancesAttempt does.
+// Mesos replaces the whole maintenance schedule, so the rest of the instances marked to be removed are included
+func (y *Watcher) scheduleMaintenance(instance *ec2.Instance) error {
+
+	instances, err := y.notebook.describeMarkedInstances()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, markedInstance := range instances {
+		found = found || *markedInstance.InstanceId == *instance.InstanceId
+	}
+	if !found {
+		instances = append(instances, instance)
+	}
+
+	y.notebook.setAgentsInMaintenance(append(instances, y.notebook.detachedInstancesList()...))
+	return nil
+}
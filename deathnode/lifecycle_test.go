@@ -0,0 +1,68 @@
+package deathnode
+
+import (
+	"testing"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/mesos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLifecycleListener(t *testing.T) {
+
+	Convey("When a lifecycle notification is received", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":   {"node_with_tag", "node2", "node3", "node_with_tag"},
+				"DescribeInstancesByTag": {"one_undesired_host"},
+				"DescribeAGByName":       {"one_undesired_host", "one_undesired_host_one_terminating"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"notasks"},
+			},
+		}
+		watcher := newWatcher(testCollectionValues{awsConn: awsConn, mesosConn: mesosConn})
+		watcher.autoscalingServiceMonitor.Refresh()
+		awsConn.FlushMock()
+
+		queue := &aws.MemoryQueue{}
+		listener := NewLifecycleListener(queue, watcher, 0)
+
+		Convey("for a marked instance, it should be destroyed straight away with the lifecycle action token", func() {
+			queue.Send(`{"AutoScalingGroupName":"some-Autoscaling-Group","EC2InstanceId":"i-34719eb8",` +
+				`"LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","LifecycleHookName":"DEATHNODE",` +
+				`"LifecycleActionToken":"71514b9d-6a40-4b26-8523-05e7ee35fa40"}`)
+			So(listener.Poll(), ShouldBeNil)
+			So(awsConn.Requests["DescribeAGByName"], ShouldResemble, [][]string{{"some-Autoscaling-Group"}})
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-34719eb8", "CONTINUE", "71514b9d-6a40-4b26-8523-05e7ee35fa40"}})
+			So(queue.Len(), ShouldEqual, 0)
+		})
+		Convey("for a marked instance, it should be put in maintenance before being destroyed", func() {
+			queue.Send(`{"AutoScalingGroupName":"some-Autoscaling-Group","EC2InstanceId":"i-34719eb8",` +
+				`"LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","LifecycleHookName":"DEATHNODE"}`)
+			So(listener.Poll(), ShouldBeNil)
+			So(*mesosConn.Requests["SetHostInMaintenance"], ShouldContain, "10.0.0.2")
+			results, _ := watcher.Readiness()
+			So(results[ComponentMesos], ShouldEqual, "ok")
+		})
+		Convey("for an autoscaling group not monitored, it should be deleted without doing anything", func() {
+			queue.Send(`{"AutoScalingGroupName":"other-Autoscaling-Group","EC2InstanceId":"i-0a1b2c3d",` +
+				`"LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","LifecycleHookName":"DEATHNODE"}`)
+			So(listener.Poll(), ShouldBeNil)
+			So(awsConn.Requests, ShouldBeEmpty)
+			So(queue.Len(), ShouldEqual, 0)
+		})
+		Convey("for another lifecycle hook, it should be deleted without doing anything", func() {
+			queue.Send(`{"AutoScalingGroupName":"some-Autoscaling-Group","EC2InstanceId":"i-34719eb8",` +
+				`"LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","LifecycleHookName":"OTHER"}`)
+			So(listener.Poll(), ShouldBeNil)
+			So(awsConn.Requests, ShouldBeEmpty)
+			So(queue.Len(), ShouldEqual, 0)
+		})
+	})
+}
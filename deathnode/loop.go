@@ -0,0 +1,89 @@
+package deathnode
+
+// Triggers the Watcher periodically, never allowing two runs to be in flight at the same time
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Loop runs a Watcher on every polling interval. Ticks received while a run is still in flight are skipped
+type Loop struct {
+	watcher      *Watcher
+	interval     time.Duration
+	runTimeout   time.Duration
+	running      int32
+	skippedTicks int64
+	wg           sync.WaitGroup
+}
+
+// NewLoop returns a new Loop object. A runTimeout of 0 disables the per-run deadline
+func NewLoop(watcher *Watcher, interval, runTimeout time.Duration) *Loop {
+
+	return &Loop{
+		watcher:    watcher,
+		interval:   interval,
+		runTimeout: runTimeout,
+	}
+}
+
+// Start triggers a run immediately and on every tick afterwards, until stop is closed. Once stopped,
+// it waits for the in-flight run to finish before returning
+func (l *Loop) Start(stop <-chan struct{}) {
+
+	ticker := l.watcher.ctx.Clock.Ticker(l.interval)
+	defer ticker.Stop()
+
+	l.tick()
+	for {
+		select {
+		case <-stop:
+			log.Info("Stop requested. Waiting for the current iteration to finish")
+			l.wg.Wait()
+			return
+		case <-ticker.C:
+			l.tick()
+		}
+	}
+}
+
+// SkippedTicks returns the number of ticks skipped because a run was still in flight
+func (l *Loop) SkippedTicks() int64 {
+	return atomic.LoadInt64(&l.skippedTicks)
+}
+
+// IsRunning returns true while a run is in flight
+func (l *Loop) IsRunning() bool {
+	return atomic.LoadInt32(&l.running) == 1
+}
+
+func (l *Loop) tick() {
+
+	if !atomic.CompareAndSwapInt32(&l.running, 0, 1) {
+		skippedTicks := atomic.AddInt64(&l.skippedTicks, 1)
+		log.Warnf("Previous iteration still running. Skipping tick (%d skipped so far)", skippedTicks)
+		return
+	}
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer atomic.StoreInt32(&l.running, 0)
+		l.run()
+	}()
+}
+
+func (l *Loop) run() {
+
+	deadline := time.Time{}
+	if l.runTimeout > 0 {
+		deadline = l.watcher.ctx.Clock.Now().Add(l.runTimeout)
+	}
+
+	if err := l.watcher.RunUntil(deadline); err != nil {
+		log.Error(err)
+	}
+}
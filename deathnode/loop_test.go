@@ -0,0 +1,57 @@
+package deathnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/mesos"
+	"github.com/benbjohnson/clock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoop(t *testing.T) {
+
+	Convey("When running the watcher loop", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":   {"node1", "node2", "node3"},
+				"DescribeInstancesByTag": {"one_undesired_host"},
+				"DescribeAGByName":       {"one_undesired_host"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"notasks"},
+			},
+		}
+		watcher := newWatcher(testCollectionValues{awsConn: awsConn, mesosConn: mesosConn})
+		clockMock := clock.NewMock()
+		watcher.ctx.Clock = clockMock
+		loop := NewLoop(watcher, time.Minute, 0)
+
+		Convey("ticks received while a run is in flight should be skipped and counted", func() {
+			loop.running = 1
+			loop.tick()
+			loop.tick()
+			So(loop.SkippedTicks(), ShouldEqual, 2)
+			So(awsConn.Requests, ShouldBeNil)
+		})
+		Convey("stopping it should wait for the current iteration to finish", func() {
+			stop := make(chan struct{})
+			close(stop)
+			loop.Start(stop)
+			So(loop.IsRunning(), ShouldBeFalse)
+			So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 1)
+			So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldHaveLength, 1)
+		})
+		Convey("a run exceeding its deadline should skip its remaining steps", func() {
+			err := watcher.RunUntil(clockMock.Now().Add(-time.Second))
+			So(err, ShouldNotBeNil)
+			So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+			So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldBeNil)
+		})
+	})
+}
@@ -7,17 +7,26 @@ import (
 	"time"
 
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/metrics"
 	"github.com/alanbover/deathnode/monitor"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	log "github.com/sirupsen/logrus"
 )
 
+// autoscalingGroupNameTag is set by AWS on every instance launched by an autoscaling group
+const autoscalingGroupNameTag = "aws:autoscaling:groupName"
+
 // Notebook stores the necessary information for deal with instances that should be deleted
 type Notebook struct {
 	mesosMonitor        *monitor.MesosMonitor
 	auroraMonitor       *monitor.AuroraMonitor
 	autoscalingGroups   *monitor.AutoscalingServiceMonitor
-	lastDeleteTimestamp time.Time
+	lastDeleteTimestamp map[string]time.Time
+	orphans             []*ec2.Instance
+	detachedInstances   []detachedInstance
+	pause               pauseSwitch
 	ctx                 *context.ApplicationContext
 }
 
@@ -25,13 +34,16 @@ type Notebook struct {
 func NewNotebook(ctx *context.ApplicationContext, autoscalingGroups *monitor.AutoscalingServiceMonitor,
 	mesosMonitor *monitor.MesosMonitor, auroraMonitor *monitor.AuroraMonitor) *Notebook {
 
-	return &Notebook{
+	notebook := &Notebook{
 		mesosMonitor:        mesosMonitor,
 		auroraMonitor:       auroraMonitor,
 		autoscalingGroups:   autoscalingGroups,
-		lastDeleteTimestamp: time.Time{},
+		lastDeleteTimestamp: map[string]time.Time{},
 		ctx:                 ctx,
 	}
+	// The monitors read the global pause switch, so they don't adopt terminations while paused
+	ctx.Paused = notebook.pause.isPaused
+	return notebook
 }
 
 func (n *Notebook) setAgentsInMaintenance(instances []*ec2.Instance) error {
@@ -41,11 +53,61 @@ func (n *Notebook) setAgentsInMaintenance(instances []*ec2.Instance) error {
 		hosts[*instance.PrivateDnsName] = *instance.PrivateIpAddress
 	}
 
+	var err error
 	if n.ctx.Conf.AuroraURL != "" {
-		return n.auroraMonitor.StartMaintenance(hosts)
+		err = n.auroraMonitor.StartMaintenance(hosts)
+	} else {
+		err = n.mesosMonitor.SetMesosAgentsInMaintenance(hosts)
 	}
 
-	return n.mesosMonitor.SetMesosAgentsInMaintenance(hosts)
+	for _, instance := range instances {
+		if n.isMaintenanceScheduled(instance, err) {
+			continue
+		}
+		n.emitInstanceEvent(instance, events.MaintenanceScheduled, nil, err)
+		if n.ctx.Conf.AuroraURL == "" {
+			// Mesos starts draining the agents in maintenance straight away, sending inverse offers to frameworks
+			n.emitInstanceEvent(instance, events.DrainStarted, nil, err)
+		}
+	}
+	return err
+}
+
+// isMaintenanceScheduled is true when the maintenance of the instance was already scheduled on a previous call,
+// so it's only reported the first time. Instances no longer monitored were scheduled while they were
+func (n *Notebook) isMaintenanceScheduled(instance *ec2.Instance, err error) bool {
+
+	if n.ctx.Conf.AuroraURL != "" && n.auroraMonitor.MaintenanceMode(*instance.PrivateIpAddress) != "NONE" {
+		return true
+	}
+	instanceMonitor, monitorErr := n.autoscalingGroups.GetInstanceByID(*instance.InstanceId)
+	if monitorErr != nil {
+		return err == nil
+	}
+	return err == nil && !instanceMonitor.SetMaintenanceScheduled()
+}
+
+// emitInstanceEvent emits an event for an instance marked to be removed, which may not be monitored
+func (n *Notebook) emitInstanceEvent(instance *ec2.Instance, eventType string, details map[string]interface{},
+	err error) {
+
+	if instanceMonitor, monitorErr := n.autoscalingGroups.GetInstanceByID(*instance.InstanceId); monitorErr == nil {
+		instanceMonitor.EmitEvent(eventType, details, err)
+		return
+	}
+
+	event := events.Event{
+		Type:       eventType,
+		InstanceID: *instance.InstanceId,
+		IP:         *instance.PrivateIpAddress,
+		Details:    details,
+	}
+	if err != nil {
+		event.Details = map[string]interface{}{"action": eventType}
+		event.Type = events.Error
+		event.Error = err.Error()
+	}
+	n.ctx.Emit(event)
 }
 
 func (n *Notebook) drainAgent(instance *ec2.Instance) error {
@@ -58,28 +120,42 @@ func (n *Notebook) drainAgent(instance *ec2.Instance) error {
 		"ip":          *instance.PrivateIpAddress,
 	}).Info("Draining Mesos agent")
 
-	return n.auroraMonitor.DrainHosts(hosts)
+	alreadyDraining := n.auroraMonitor.IsDraining(*instance.PrivateIpAddress) ||
+		n.auroraMonitor.IsDrained(*instance.PrivateIpAddress)
+	err := n.auroraMonitor.DrainHosts(hosts)
+	if !alreadyDraining {
+		n.emitInstanceEvent(instance, events.DrainStarted, nil, err)
+	}
+	return err
 }
 
 func (n *Notebook) endMaintenance(instanceMonitor *monitor.InstanceMonitor) error {
+	return n.endHostMaintenance(*instanceMonitor.InstanceID(), instanceMonitor.IP())
+}
+
+func (n *Notebook) endHostMaintenance(instanceID, ip string) error {
 
 	hosts := map[string]string{}
-	hosts[instanceMonitor.IP()] = instanceMonitor.IP()
+	hosts[ip] = ip
 
 	log.WithFields(log.Fields{
-		"instance_id": *instanceMonitor.InstanceID(),
-		"ip":          instanceMonitor.IP(),
+		"instance_id": instanceID,
+		"ip":          ip,
 	}).Info("Ending Mesos agent maintenance")
 
 	return n.auroraMonitor.EndMaintenance(hosts)
 }
 
-func (n *Notebook) shouldWaitForNextDestroy() bool {
+// shouldWaitForNextDestroy returns true if an instance has been destroyed in the autoscaling group selector
+// less than DelayDeleteSeconds ago
+func (n *Notebook) shouldWaitForNextDestroy(autoscalingMonitor *monitor.AutoscalingGroupMonitor) bool {
 
-	return n.ctx.Clock.Since(n.lastDeleteTimestamp).Seconds() <= float64(n.ctx.Conf.DelayDeleteSeconds)
+	lastDeleteTimestamp := n.lastDeleteTimestamp[autoscalingMonitor.Conf().ID()]
+	return n.ctx.Clock.Since(lastDeleteTimestamp).Seconds() <= float64(autoscalingMonitor.Settings().DelayDeleteSeconds)
 }
 
-func (n *Notebook) destroyInstance(instanceMonitor *monitor.InstanceMonitor) error {
+func (n *Notebook) destroyInstance(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) error {
 
 	if instanceMonitor.LifecycleState() == monitor.LifecycleStateTerminatingWait {
 		// ensure we end maintenance for this instance after it's been destroyed.
@@ -89,28 +165,128 @@ func (n *Notebook) destroyInstance(instanceMonitor *monitor.InstanceMonitor) err
 		}
 
 		log.Infof("Destroy instance %s", *instanceMonitor.InstanceID())
-		err := n.ctx.AwsConn.CompleteLifecycleAction(
-			instanceMonitor.AutoscalingGroupID(), instanceMonitor.InstanceID())
+		err := instanceMonitor.ContinueLifecycleAction()
+		instanceMonitor.EmitEvent(events.LifecycleCompleted, map[string]interface{}{
+			"markTimestamp": instanceMonitor.MarkTimestamp(),
+		}, err)
 		if err != nil {
 			log.Errorf("Unable to complete lifecycle action on instance %s", *instanceMonitor.InstanceID())
 			return err
 		}
-		if n.ctx.Conf.DelayDeleteSeconds != 0 {
-			n.lastDeleteTimestamp = n.ctx.Clock.Now()
+		metrics.LifecycleActionsCompleted.Inc(autoscalingMonitor.GetAutoscalingGroupName())
+		n.recordDestroy(autoscalingMonitor, instanceMonitor)
+	} else if instanceMonitor.IsQuarantined() {
+		return n.enterStandby(autoscalingMonitor, instanceMonitor)
+	} else if n.isDetachMode(autoscalingMonitor) {
+		return n.detachInstance(autoscalingMonitor, instanceMonitor)
+	} else if instanceMonitor.IsManuallyMarked() {
+		// Nothing will scale in a manually marked instance, so terminate it keeping the desired capacity
+		log.Infof("Requesting termination of manually marked instance %s", *instanceMonitor.InstanceID())
+		err := instanceMonitor.RequestTermination()
+		instanceMonitor.EmitEvent(events.TerminationRequested, nil, err)
+		if err != nil {
+			log.Errorf("Unable to terminate instance %s", *instanceMonitor.InstanceID())
+			return err
 		}
 	} else {
+		// With the standby DrainFailurePolicy, the scale-in protection is only removed once drained
+		if err := n.removeInstanceProtection(instanceMonitor); err != nil {
+			return err
+		}
 		log.Debugf("Instance %s waiting for AWS to start termination lifecycle", *instanceMonitor.InstanceID())
 	}
 	return nil
 }
 
-func (n *Notebook) resetLifecycle(instanceMonitor *monitor.InstanceMonitor) {
+// enterStandby moves a quarantined instance to Standby, instead of terminating it. It stays in maintenance
+// until its quarantine ends. The desired capacity is only decremented if it doesn't account for the removal yet
+func (n *Notebook) enterStandby(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) error {
+
+	if instanceMonitor.IsStandbyRequested() {
+		log.Debugf("Instance %s waiting for AWS to move it to %s", *instanceMonitor.InstanceID(),
+			monitor.LifecycleStateStandby)
+		return nil
+	}
+
+	log.Infof("Moving quarantined instance %s to %s", *instanceMonitor.InstanceID(), monitor.LifecycleStateStandby)
+	err := instanceMonitor.EnterStandby(!autoscalingMonitor.IsAboveDesiredCapacity())
+	instanceMonitor.EmitEvent(events.StandbyEntered, map[string]interface{}{
+		"markTimestamp": instanceMonitor.MarkTimestamp(),
+	}, err)
+	if err != nil {
+		log.Errorf("Unable to move instance %s to %s", *instanceMonitor.InstanceID(), monitor.LifecycleStateStandby)
+		return err
+	}
+	return nil
+}
+
+// recordDestroy observes the removal duration of a destroyed instance and starts its autoscaling group delayDelete
+func (n *Notebook) recordDestroy(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) {
+
+	if instanceMonitor.MarkTimestamp() != 0 {
+		metrics.InstanceRemovalDuration.Observe(
+			n.ctx.Clock.Since(time.Unix(instanceMonitor.MarkTimestamp(), 0)).Seconds(),
+			autoscalingMonitor.GetAutoscalingGroupName())
+	}
+	if autoscalingMonitor.Settings().DelayDeleteSeconds != 0 {
+		n.lastDeleteTimestamp[autoscalingMonitor.Conf().ID()] = n.ctx.Clock.Now()
+	}
+}
+
+// isDetachMode is true when the autoscaling group removes its instances detaching and terminating them
+func (n *Notebook) isDetachMode(autoscalingMonitor *monitor.AutoscalingGroupMonitor) bool {
+	return autoscalingMonitor.Settings().RemovalMode == context.RemovalDetach
+}
+
+// cancelMaintenance takes the instance out of maintenance: on Aurora ending it, on Mesos scheduling the
+// maintenance again for the rest of the instances marked to be removed
+func (n *Notebook) cancelMaintenance(instanceMonitor *monitor.InstanceMonitor) error {
+
+	if n.ctx.Conf.AuroraURL != "" {
+		return n.endMaintenance(instanceMonitor)
+	}
+
+	instances, err := n.describeMarkedInstances()
+	if err != nil {
+		return err
+	}
+
+	hosts := map[string]string{}
+	for _, instance := range append(instances, n.detachedInstancesList()...) {
+		if *instance.InstanceId != *instanceMonitor.InstanceID() {
+			hosts[*instance.PrivateDnsName] = *instance.PrivateIpAddress
+		}
+	}
+	return n.mesosMonitor.SetMesosAgentsInMaintenance(hosts)
+}
+
+// forceDestroy destroys the instance without waiting for its tasks to finish or for delayDelete
+func (n *Notebook) forceDestroy(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) error {
+
+	if !n.isDetachMode(autoscalingMonitor) && !instanceMonitor.IsQuarantined() {
+		if err := n.removeInstanceProtection(instanceMonitor); err != nil {
+			return err
+		}
+	}
+	return n.destroyInstance(autoscalingMonitor, instanceMonitor)
+}
+
+func (n *Notebook) resetLifecycle(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) {
 
 	// Check if timeout is close to expire
 	startTimeoutTimestamp := time.Unix(instanceMonitor.TagRemovalTimestamp(), 0)
-	maxSecondsToRefresh := float64(n.ctx.Conf.LifecycleTimeout) * monitor.LifeCycleRefreshTimeoutPercentage
+	maxSecondsToRefresh := float64(autoscalingMonitor.Settings().LifecycleTimeout) * monitor.LifeCycleRefreshTimeoutPercentage
 
 	if instanceMonitor.LifecycleState() == monitor.LifecycleStateTerminatingWait && n.ctx.Clock.Since(startTimeoutTimestamp).Seconds() > maxSecondsToRefresh {
+		if remaining, ok := n.lifecycleRemaining(autoscalingMonitor, instanceMonitor); ok && remaining <= 0 {
+			log.Warnf("Lifecycle action of instance %s exceeded the AWS global timeout. Not refreshing it",
+				*instanceMonitor.InstanceID())
+			return
+		}
 		err := instanceMonitor.RefreshLifecycleHook()
 		if err != nil {
 			log.Errorf("Unable to reset lifecycle hook for instance %s", *instanceMonitor.InstanceID())
@@ -118,6 +294,11 @@ func (n *Notebook) resetLifecycle(instanceMonitor *monitor.InstanceMonitor) {
 	}
 }
 
+// isPaused is true when removals are paused globally or on the autoscaling group
+func (n *Notebook) isPaused(autoscalingMonitor *monitor.AutoscalingGroupMonitor) bool {
+	return n.pause.isPaused() || autoscalingMonitor.IsPaused()
+}
+
 func (n *Notebook) destroyInstanceAttempt(instance *ec2.Instance) error {
 
 	log.Debugf("Starting process to delete instance %s", *instance.InstanceId)
@@ -127,18 +308,57 @@ func (n *Notebook) destroyInstanceAttempt(instance *ec2.Instance) error {
 		return err
 	}
 
-	// If the instance is protected, remove instance protection
-	n.removeInstanceProtection(instanceMonitor)
+	autoscalingMonitor, err := n.autoscalingGroups.GetAutoscalingGroupMonitorByInstanceID(*instance.InstanceId)
+	if err != nil {
+		return err
+	}
+
+	// Quarantined instances already in Standby are only kept in maintenance
+	if instanceMonitor.LifecycleState() == monitor.LifecycleStateStandby {
+		log.Debugf("Instance %s is in %s. It will not be destroyed", *instance.InstanceId,
+			monitor.LifecycleStateStandby)
+		return nil
+	}
+
+	n.alertLifecycleDeadline(autoscalingMonitor, instanceMonitor)
+
+	// While paused, only keep the lifecycle action alive, so AWS doesn't apply its default result on timeout
+	if n.isPaused(autoscalingMonitor) {
+		log.Debugf("Removals paused. Instance %s will not be destroyed", *instance.InstanceId)
+		n.resetLifecycle(autoscalingMonitor, instanceMonitor)
+		return nil
+	}
+
+	// If the instance is protected, remove instance protection, so AWS can scale it in. Detached and
+	// quarantined instances don't need it, and the standby DrainFailurePolicy waits for the drain
+	if !n.isDetachMode(autoscalingMonitor) && !instanceMonitor.IsQuarantined() &&
+		autoscalingMonitor.Settings().DrainFailurePolicy != context.DrainFailureStandby {
+		n.removeInstanceProtection(instanceMonitor)
+	}
 
 	// Reset lifecycle hook timeout if needed
 	if n.ctx.Conf.ResetLifecycle {
-		n.resetLifecycle(instanceMonitor)
+		n.resetLifecycle(autoscalingMonitor, instanceMonitor)
+	}
+
+	if abandoned, err := n.escalateLifecycleDeadline(autoscalingMonitor, instanceMonitor); abandoned || err != nil {
+		return err
+	}
+
+	if instanceMonitor.IsForcedDestroy() {
+		log.Debugf("Instance %s forced to be destroyed", *instance.InstanceId)
+		return n.destroyInstance(autoscalingMonitor, instanceMonitor)
+	}
+
+	if n.isUnhealthyDrainOver(autoscalingMonitor, instanceMonitor) {
+		log.Infof("Unhealthy instance %s drain is over. Destroying it", *instance.InstanceId)
+		return n.destroyInstance(autoscalingMonitor, instanceMonitor)
 	}
 
 	// Check if we need to wait before destroy another instance
-	if n.shouldWaitForNextDestroy() {
+	if n.shouldWaitForNextDestroy(autoscalingMonitor) {
 		log.Debugf("Seconds since last destroy: %v. Instance %s will not be destroyed",
-			n.ctx.Clock.Since(n.lastDeleteTimestamp).Seconds(), *instance.InstanceId)
+			n.ctx.Clock.Since(n.lastDeleteTimestamp[autoscalingMonitor.Conf().ID()]).Seconds(), *instance.InstanceId)
 		return nil
 	}
 
@@ -152,7 +372,7 @@ func (n *Notebook) destroyInstanceAttempt(instance *ec2.Instance) error {
 
 		// If the instance can be killed, delete it
 		if n.auroraMonitor.IsDrained(*instance.PrivateIpAddress) {
-			if err := n.destroyInstance(instanceMonitor); err != nil {
+			if err := n.destroyInstance(autoscalingMonitor, instanceMonitor); err != nil {
 				return err
 			}
 
@@ -161,11 +381,119 @@ func (n *Notebook) destroyInstanceAttempt(instance *ec2.Instance) error {
 
 	}
 
-	if !n.mesosMonitor.IsProtected(*instance.PrivateIpAddress) {
-		if err := n.destroyInstance(instanceMonitor); err != nil {
+	settings := autoscalingMonitor.Settings()
+	mesosMonitor := n.mesosMonitor.WithProtection(settings.ProtectedFrameworks, settings.ProtectedTasksLabels)
+	if !mesosMonitor.IsProtected(*instance.PrivateIpAddress) {
+		return n.destroyInstance(autoscalingMonitor, instanceMonitor)
+	}
+
+	if n.isDrainTimedOut(autoscalingMonitor, instanceMonitor) {
+		return n.failDrain(autoscalingMonitor, instanceMonitor)
+	}
+	return nil
+}
+
+// isUnhealthyDrainOver is true for the unhealthy instances terminated outside deathnode that shouldn't wait
+// for their tasks anymore: straight away with the complete UnhealthyPolicy, or after UnhealthyDrainTimeout
+// seconds in Terminating:Wait with the drain one
+func (n *Notebook) isUnhealthyDrainOver(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) bool {
+
+	if !instanceMonitor.IsExternalTermination() || !instanceMonitor.IsUnhealthy() ||
+		instanceMonitor.LifecycleState() != monitor.LifecycleStateTerminatingWait {
+		return false
+	}
+
+	settings := autoscalingMonitor.Settings()
+	if settings.UnhealthyPolicy == context.UnhealthyComplete {
+		return true
+	}
+	return n.ctx.Clock.Since(instanceMonitor.TerminatingWaitSince()).Seconds() >= float64(settings.UnhealthyDrainTimeout)
+}
+
+// isDrainTimedOut is true when the instance is still running protected tasks DrainTimeout seconds after
+// being marked. Only instances in Terminating:Wait are considered, since there is no lifecycle action before,
+// except with the standby DrainFailurePolicy, which keeps the instances InService until drained
+func (n *Notebook) isDrainTimedOut(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) bool {
+
+	settings := autoscalingMonitor.Settings()
+	if settings.DrainTimeout <= 0 || instanceMonitor.MarkTimestamp() == 0 {
+		return false
+	}
+	if settings.DrainFailurePolicy != context.DrainFailureStandby &&
+		instanceMonitor.LifecycleState() != monitor.LifecycleStateTerminatingWait {
+		return false
+	}
+	return n.ctx.Clock.Since(time.Unix(instanceMonitor.MarkTimestamp(), 0)).Seconds() >= float64(settings.DrainTimeout)
+}
+
+// failDrain applies the DrainFailurePolicy of the autoscaling group to an instance whose drain failed: with
+// abandon, its lifecycle action is completed with ABANDON. With standby, its removal is abandoned, keeping it
+// running in Standby. With heartbeat, or with standby once in Terminating:Wait, as AWS terminates it on both
+// lifecycle action results, its lifecycle action is kept alive until the tasks finish, even without
+// -resetLifecycle
+func (n *Notebook) failDrain(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) error {
+
+	policy := autoscalingMonitor.Settings().DrainFailurePolicy
+	if instanceMonitor.SetDrainFailed() {
+		log.Warnf("Drain of instance %s failed: protected tasks still running %s after being marked. Applying policy %s",
+			*instanceMonitor.InstanceID(), n.ctx.Clock.Since(time.Unix(instanceMonitor.MarkTimestamp(), 0)), policy)
+		instanceMonitor.EmitEvent(events.DrainFailed, map[string]interface{}{"policy": policy}, nil)
+	}
+
+	isTerminatingWait := instanceMonitor.LifecycleState() == monitor.LifecycleStateTerminatingWait
+	if policy == context.DrainFailureAbandon {
+		return n.abandonLifecycleAction(autoscalingMonitor, instanceMonitor, "drain failed")
+	}
+	if policy == context.DrainFailureStandby && !isTerminatingWait {
+		return n.keepInStandby(autoscalingMonitor, instanceMonitor)
+	}
+	n.resetLifecycle(autoscalingMonitor, instanceMonitor)
+	return nil
+}
+
+// keepInStandby keeps running an instance whose drain failed, quarantining it so it's moved to Standby and
+// kept there until an operator ends its quarantine
+func (n *Notebook) keepInStandby(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) error {
+
+	if !instanceMonitor.IsQuarantined() {
+		log.Infof("Abandoning removal of instance %s. Quarantining it", *instanceMonitor.InstanceID())
+		err := instanceMonitor.Quarantine()
+		instanceMonitor.EmitEvent(events.InstanceMarked, map[string]interface{}{
+			"quarantine": true,
+			"reason":     "drain failed",
+		}, err)
+		if err != nil {
+			log.Errorf("Unable to quarantine instance %s", *instanceMonitor.InstanceID())
 			return err
 		}
 	}
+	return n.enterStandby(autoscalingMonitor, instanceMonitor)
+}
+
+// abandonLifecycleAction completes the lifecycle action of an instance in Terminating:Wait with ABANDON
+func (n *Notebook) abandonLifecycleAction(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor, reason string) error {
+
+	if n.ctx.Conf.AuroraURL != "" {
+		defer n.endMaintenance(instanceMonitor)
+	}
+
+	log.Infof("Abandon lifecycle action of instance %s (%s)", *instanceMonitor.InstanceID(), reason)
+	err := instanceMonitor.AbandonLifecycleAction()
+	instanceMonitor.EmitEvent(events.LifecycleAbandoned, map[string]interface{}{
+		"markTimestamp": instanceMonitor.MarkTimestamp(),
+		"reason":        reason,
+	}, err)
+	if err != nil {
+		log.Errorf("Unable to abandon lifecycle action on instance %s", *instanceMonitor.InstanceID())
+		return err
+	}
+	metrics.LifecycleActionsCompleted.Inc(autoscalingMonitor.GetAutoscalingGroupName())
+	n.recordDestroy(autoscalingMonitor, instanceMonitor)
 	return nil
 }
 
@@ -176,24 +504,96 @@ func (n *Notebook) destroyInstanceAttempt(instance *ec2.Instance) error {
 func (n *Notebook) DestroyInstancesAttempt() error {
 
 	// Get instances marked for removal
-	instances, err := n.ctx.AwsConn.DescribeInstancesByTag(n.ctx.Conf.DeathNodeMark)
+	instances, err := n.describeMarkedInstances()
 	if err != nil {
 		log.Debugf("Error retrieving instances with tag %s", n.ctx.Conf.DeathNodeMark)
 		return err
 	}
 
 	// Set instances in maintenance
-	n.setAgentsInMaintenance(instances)
+	n.setAgentsInMaintenance(append(instances, n.detachedInstancesList()...))
 
 	for _, instance := range instances {
 		if err := n.destroyInstanceAttempt(instance); err != nil {
 			log.Warn(err)
 		}
 	}
+	n.terminateDetachedInstances()
 
 	return nil
 }
 
+// describeMarkedInstances returns the instances with the DeathNodeMark tag on every AWS target that belong
+// to a monitored autoscaling group. The ones detached by deathnode are kept apart to be terminated, and the
+// rest as orphans
+func (n *Notebook) describeMarkedInstances() ([]*ec2.Instance, error) {
+
+	instances := []*ec2.Instance{}
+	orphans := []*ec2.Instance{}
+	detachedInstances := []detachedInstance{}
+	seen := map[string]bool{}
+	for _, awsConn := range n.ctx.AwsConns() {
+		targetInstances, err := awsConn.DescribeInstancesByTag(n.ctx.Conf.DeathNodeMark)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range targetInstances {
+			if seen[*instance.InstanceId] {
+				continue
+			}
+			seen[*instance.InstanceId] = true
+			if _, err := n.autoscalingGroups.GetInstanceByID(*instance.InstanceId); err == nil {
+				instances = append(instances, instance)
+			} else if instanceTag(instance, n.ctx.Conf.DeathNodeMark+monitor.DetachedMarkSuffix) != "" {
+				detachedInstances = append(detachedInstances, detachedInstance{instance: instance, awsConn: awsConn})
+			} else {
+				orphans = append(orphans, instance)
+			}
+		}
+	}
+
+	n.detachedInstances = detachedInstances
+	n.setOrphans(orphans)
+	return instances, nil
+}
+
+// setOrphans replaces the orphan instances, reporting the ones not found on the previous call
+func (n *Notebook) setOrphans(orphans []*ec2.Instance) {
+
+	known := map[string]bool{}
+	for _, orphan := range n.orphans {
+		known[*orphan.InstanceId] = true
+	}
+
+	for _, orphan := range orphans {
+		if known[*orphan.InstanceId] {
+			continue
+		}
+		autoscalingGroupName := instanceTag(orphan, autoscalingGroupNameTag)
+		log.Warnf("Instance %s has tag %s but doesn't belong to any monitored autoscaling group (%s). Ignoring it",
+			*orphan.InstanceId, n.ctx.Conf.DeathNodeMark, autoscalingGroupName)
+		n.ctx.Emit(events.Event{
+			Type:             events.OrphanFound,
+			AutoscalingGroup: autoscalingGroupName,
+			InstanceID:       *orphan.InstanceId,
+			IP:               aws.StringValue(orphan.PrivateIpAddress),
+		})
+	}
+
+	n.orphans = orphans
+}
+
+// instanceTag returns the value of a tag of the instance, or an empty string if it's not set
+func instanceTag(instance *ec2.Instance, key string) string {
+
+	for _, tag := range instance.Tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
+
 func (n *Notebook) removeInstanceProtection(instance *monitor.InstanceMonitor) error {
 
 	if instance.IsProtected() {
@@ -6,7 +6,9 @@ import (
 
 	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
 	"github.com/alanbover/deathnode/mesos"
+	"github.com/alanbover/deathnode/metrics"
 	"github.com/alanbover/deathnode/monitor"
 	"github.com/benbjohnson/clock"
 	. "github.com/smartystreets/goconvey/convey"
@@ -36,12 +38,12 @@ func TestRecordLifecycleActionHeartbeat(t *testing.T) {
 			},
 		}
 		notebook := newNotebook(awsConn, mesosConn, 0, clockMock)
-		Convey("it should have recorded lifecycleAction and reset tag for the already terminating instance", func() {
+		Convey("it should not have recorded lifecycleAction for the already marked terminating instance", func() {
 			clockMock.Set(time.Unix(1190997840, 0))
 			notebook.DestroyInstancesAttempt()
 			clockMock.Set(time.Unix(1190995200, 0))
-			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldHaveLength, 1)
-			So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 1)
+			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldBeNil)
+			So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
 		})
 		Convey("it should do nothing if no lifecycle to be refreshed", func() {
 			awsConn.FlushMock()
@@ -53,11 +55,33 @@ func TestRecordLifecycleActionHeartbeat(t *testing.T) {
 		})
 		Convey("it should refresh lifecycle if time is close to be expired", func() {
 			awsConn.FlushMock()
+			recorder := &events.Recorder{}
+			notebook.ctx.Events = recorder
+			tagsSet := metrics.TagsSet.Value("some-Autoscaling-Group")
 			clockMock.Set(time.Unix(1190997960, 0))
 			notebook.DestroyInstancesAttempt()
 			clockMock.Set(time.Unix(1190995200, 0))
 			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldHaveLength, 1)
-			So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 1)
+			So(awsConn.Requests["SetInstanceTag"], ShouldContain,
+				[]string{"DEATH_NODE_MARK", "1190997960", "i-34719eb8"})
+			Convey("without emitting a tag_applied event for the re-tag", func() {
+				So(recorder.OfType(events.LifecycleHeartbeat), ShouldHaveLength, 1)
+				So(recorder.OfType(events.TagApplied), ShouldBeEmpty)
+			})
+			Convey("without counting the re-tag as a mark", func() {
+				So(metrics.TagsSet.Value("some-Autoscaling-Group"), ShouldEqual, tagsSet)
+			})
+		})
+		Convey("on its first refresh, it should store when it was marked and when it entered Terminating:Wait", func() {
+			awsConn.FlushMock()
+			clockMock.Set(time.Unix(1190997960, 0))
+			notebook.DestroyInstancesAttempt()
+			clockMock.Set(time.Unix(1190995200, 0))
+			So(awsConn.Requests["SetInstanceTag"], ShouldResemble, [][]string{
+				{"DEATH_NODE_MARK_MARKED_AT", "1190995200", "i-34719eb8"},
+				{"DEATH_NODE_MARK_TERMINATING_WAIT", "1190995200", "i-34719eb8"},
+				{"DEATH_NODE_MARK", "1190997960", "i-34719eb8"},
+			})
 		})
 	})
 }
@@ -182,6 +206,347 @@ func TestDestroyInstanceAttempt(t *testing.T) {
 	})
 }
 
+func TestDestroyInstanceAttemptPaused(t *testing.T) {
+
+	clockMock := clock.NewMock()
+	clockMock.Set(time.Unix(1190995200, 0))
+
+	Convey("When removals are paused on an autoscaling group with a drained instance in Terminating:Wait", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node_with_tag", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"one_undesired_host", "one_undesired_host"},
+				"DescribeAGByName":       {"paused_one_terminating"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"notasks"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clockMock)
+		notebook.ctx.Conf.ResetLifecycle = false
+		autoscalingMonitor := notebook.autoscalingGroups.GetAutoscalingGroupMonitorsList()[0]
+		awsConn.FlushMock()
+
+		Convey("the autoscaling group should be paused by its tag", func() {
+			So(autoscalingMonitor.IsPaused(), ShouldBeTrue)
+		})
+		Convey("the lifecycle action should not be completed", func() {
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldBeNil)
+		})
+		Convey("the lifecycle action should be heartbeated when close to expire", func() {
+			clockMock.Set(time.Unix(1190997960, 0))
+			notebook.DestroyInstancesAttempt()
+			clockMock.Set(time.Unix(1190995200, 0))
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestDestroyInstanceAttemptDrainFailed(t *testing.T) {
+
+	clockMock := clock.NewMock()
+	clockMock.Set(time.Unix(1190995200, 0))
+
+	Convey("When an instance in Terminating:Wait keeps running protected tasks", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node_with_tag", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"one_undesired_host"},
+				"DescribeAGByName":       {"one_undesired_host_one_terminating"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clockMock)
+		notebook.ctx.Conf.ResetLifecycle = false
+		notebook.ctx.Conf.DrainTimeout = 1800
+		awsConn.FlushMock()
+
+		Convey("before drainTimeout, nothing should be done", func() {
+			clockMock.Set(time.Unix(1190996000, 0))
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldBeNil)
+		})
+		Convey("after drainTimeout with the abandon policy, the lifecycle action should be abandoned", func() {
+			notebook.ctx.Conf.DrainFailurePolicy = context.DrainFailureAbandon
+			clockMock.Set(time.Unix(1190997000, 0))
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-34719eb8", "ABANDON"}})
+		})
+		Convey("after drainTimeout with the standby policy, the lifecycle action should be kept alive", func() {
+			notebook.ctx.Conf.DrainFailurePolicy = context.DrainFailureStandby
+			clockMock.Set(time.Unix(1190997960, 0))
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			So(awsConn.Requests["EnterStandby"], ShouldBeNil)
+			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldHaveLength, 1)
+		})
+		Convey("after drainTimeout with the heartbeat policy, the lifecycle action should be kept alive", func() {
+			clockMock.Set(time.Unix(1190997960, 0))
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			So(awsConn.Requests["RecordLifecycleActionHeartbeat"], ShouldHaveLength, 1)
+		})
+		Reset(func() {
+			clockMock.Set(time.Unix(1190995200, 0))
+		})
+	})
+}
+
+func TestDestroyInstanceAttemptDrainStandby(t *testing.T) {
+
+	clockMock := clock.NewMock()
+	clockMock.Set(time.Unix(1190995200, 0))
+
+	Convey("When an instance marked with the standby drain failure policy keeps running protected tasks", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node_with_tag", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"one_undesired_host", "one_undesired_host"},
+				"DescribeAGByName":       {"one_undesired_host"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clockMock)
+		notebook.ctx.Conf.DrainTimeout = 1800
+		notebook.ctx.Conf.DrainFailurePolicy = context.DrainFailureStandby
+		recorder := &events.Recorder{}
+		notebook.ctx.Events = recorder
+		instanceMonitor, _ := notebook.autoscalingGroups.GetInstanceByID("i-34719eb8")
+		awsConn.FlushMock()
+
+		Convey("before drainTimeout, its scale-in protection should be kept until it's drained", func() {
+			clockMock.Set(time.Unix(1190996000, 0))
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldBeNil)
+			So(awsConn.Requests["EnterStandby"], ShouldBeNil)
+		})
+		Convey("after drainTimeout, its removal should be abandoned keeping it running in Standby", func() {
+			clockMock.Set(time.Unix(1190997000, 0))
+			notebook.DestroyInstancesAttempt()
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldBeNil)
+			So(instanceMonitor.IsQuarantined(), ShouldBeTrue)
+			So(awsConn.Requests["SetInstanceTag"], ShouldContain, []string{"DEATH_NODE_MARK_QUARANTINE", "true", "i-34719eb8"})
+			So(awsConn.Requests["EnterStandby"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-34719eb8", "false"}})
+			So(recorder.OfType(events.DrainFailed), ShouldHaveLength, 1)
+			So(recorder.OfType(events.StandbyEntered), ShouldHaveLength, 1)
+		})
+		Reset(func() {
+			clockMock.Set(time.Unix(1190995200, 0))
+		})
+	})
+}
+
+func TestDestroyInstanceAttemptUnhealthy(t *testing.T) {
+
+	clockMock := clock.NewMock()
+	clockMock.Set(time.Unix(1190995200, 0))
+
+	Convey("When an unhealthy instance goes to Terminating:Wait without being marked", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node1", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"one_undesired_host"},
+				"DescribeAGByName":       {"unhealthy_terminating"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clockMock)
+		notebook.ctx.Conf.ResetLifecycle = false
+		notebook.ctx.Conf.UnhealthyDrainTimeout = 300
+		instanceMonitor, _ := notebook.autoscalingGroups.GetInstanceByID("i-34719eb8")
+
+		Convey("its termination should be adopted", func() {
+			So(instanceMonitor.IsExternalTermination(), ShouldBeTrue)
+			So(instanceMonitor.IsMarkedToBeRemoved(), ShouldBeTrue)
+			So(awsConn.Requests["SetInstanceTag"], ShouldResemble, [][]string{
+				{"DEATH_NODE_MARK", "1190995200", "i-34719eb8"}})
+		})
+		Convey("with the drain policy", func() {
+			awsConn.FlushMock()
+			Convey("it should be drained while unhealthyDrainTimeout isn't over", func() {
+				clockMock.Set(time.Unix(1190995400, 0))
+				notebook.DestroyInstancesAttempt()
+				So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			})
+			Convey("it should be destroyed once unhealthyDrainTimeout is over", func() {
+				clockMock.Set(time.Unix(1190995500, 0))
+				notebook.DestroyInstancesAttempt()
+				So(awsConn.Requests["CompleteLifecycleAction"], ShouldResemble, [][]string{
+					{"some-Autoscaling-Group", "i-34719eb8", "CONTINUE"}})
+			})
+		})
+		Convey("with the complete policy, it should be destroyed without waiting for its tasks", func() {
+			awsConn.FlushMock()
+			notebook.ctx.Conf.UnhealthyPolicy = context.UnhealthyComplete
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["CompleteLifecycleAction"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "i-34719eb8", "CONTINUE"}})
+		})
+		Reset(func() {
+			clockMock.Set(time.Unix(1190995200, 0))
+		})
+	})
+}
+
+func TestDestroyInstanceAttemptDetach(t *testing.T) {
+
+	Convey("When an instance marked to be removed belongs to an autoscaling group with the detach removal mode", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node1", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"one_undesired_host"},
+				"DescribeAGByName":       {"one_undesired_host"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clock.New())
+		notebook.ctx.Conf.RemovalMode = context.RemovalDetach
+		awsConn.FlushMock()
+
+		Convey("while it runs protected tasks, it should be neither unprotected nor detached", func() {
+			notebook.DestroyInstancesAttempt()
+			So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldBeNil)
+			So(awsConn.Requests["DetachInstance"], ShouldBeNil)
+			So(awsConn.Requests["TerminateInstance"], ShouldBeNil)
+		})
+		Convey("once drained, it should be detached and terminated while still InService", func() {
+			mesosConn.Records = map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"notasks"},
+			}
+			notebook.mesosMonitor.Refresh()
+			autoscalingMonitor := notebook.autoscalingGroups.GetAutoscalingGroupMonitorsList()[0]
+			So(autoscalingMonitor.IsAboveDesiredCapacity(), ShouldBeTrue)
+			notebook.DestroyInstancesAttempt()
+			Convey("without decrementing the desired capacity, already lowered for it", func() {
+				So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldBeNil)
+				So(awsConn.Requests["SetInstanceTag"], ShouldContain,
+					[]string{"DEATH_NODE_MARK_DETACHED", "true", "i-34719eb8"})
+				So(awsConn.Requests["DetachInstance"], ShouldResemble, [][]string{
+					{"some-Autoscaling-Group", "i-34719eb8", "false"}})
+				So(awsConn.Requests["TerminateInstance"], ShouldResemble, [][]string{{"i-34719eb8"}})
+				So(awsConn.Requests["CompleteLifecycleAction"], ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestDestroyInstancesAttemptOrphans(t *testing.T) {
+
+	Convey("When an instance outside the monitored autoscaling groups has the removal tag", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node_with_tag", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"orphan"},
+				"DescribeAGByName":       {"one_undesired_host"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clock.New())
+		notebook.DestroyInstancesAttempt()
+
+		Convey("only the instances of the monitored autoscaling groups should be set in maintenance", func() {
+			So(*mesosConn.Requests["SetHostInMaintenance"], ShouldNotContain, "10.0.1.2")
+			So(*mesosConn.Requests["SetHostInMaintenance"], ShouldContain, "10.0.0.2")
+		})
+		Convey("the orphan instance should be reported apart", func() {
+			So(notebook.orphans, ShouldHaveLength, 1)
+			So(*notebook.orphans[0].InstanceId, ShouldEqual, "i-0a1b2c3d")
+			So(instanceTag(notebook.orphans[0], autoscalingGroupNameTag), ShouldEqual, "other-Autoscaling-Group")
+		})
+	})
+}
+
+func TestDestroyInstancesAttemptDetachedOrphans(t *testing.T) {
+
+	Convey("When an instance detached by deathnode is still running outside its autoscaling group", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"node_with_tag", "node2", "node3",
+				},
+				"DescribeInstancesByTag": {"detached_orphan"},
+				"DescribeAGByName":       {"one_undesired_host"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default"},
+				"GetMesosSlaves":     {"default"},
+				"GetMesosTasks":      {"default"},
+			},
+		}
+		notebook := newNotebook(awsConn, mesosConn, 0, clock.New())
+		recorder := &events.Recorder{}
+		notebook.ctx.Events = recorder
+		notebook.DestroyInstancesAttempt()
+
+		Convey("it should be kept in maintenance", func() {
+			So(*mesosConn.Requests["SetHostInMaintenance"], ShouldContain, "10.0.1.3")
+		})
+		Convey("it should be terminated instead of reported as orphan", func() {
+			So(notebook.orphans, ShouldBeEmpty)
+			So(recorder.OfType(events.OrphanFound), ShouldBeEmpty)
+			So(awsConn.Requests["TerminateInstance"], ShouldResemble, [][]string{{"i-0d3e4f5a"}})
+			So(recorder.OfType(events.InstanceTerminated), ShouldHaveLength, 1)
+		})
+	})
+}
+
 func newNotebook(awsConn aws.ClientInterface, mesosConn mesos.ClientInterface, delayDeleteSeconds int, clk clock.Clock) *Notebook {
 
 	ctx := &context.ApplicationContext{
@@ -0,0 +1,201 @@
+package deathnode
+
+// Manual operations over the monitored instances, requested by an operator
+
+import (
+	"fmt"
+
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/monitor"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	log "github.com/sirupsen/logrus"
+)
+
+// OperatorError is returned when an operator request can't be applied to an instance
+type OperatorError struct {
+	NotFound bool
+	message  string
+}
+
+func (e *OperatorError) Error() string {
+	return e.message
+}
+
+func newConflictError(format string, args ...interface{}) error {
+	return &OperatorError{message: fmt.Sprintf(format, args...)}
+}
+
+// MarkInstance marks an instance to be removed, regardless of the autoscaling group desired capacity. It's
+// drained as any other marked instance and, once drained, terminated through its autoscaling group
+func (y *Watcher) MarkInstance(instanceID string) error {
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	instanceMonitor, autoscalingMonitor, err := y.getOperatorInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if instanceMonitor.IsMarkedToBeRemoved() {
+		return newConflictError("Instance %s is already marked to be removed", instanceID)
+	}
+	if y.notebook.isPaused(autoscalingMonitor) {
+		return newConflictError("Removals are paused on autoscaling group %s", *instanceMonitor.AutoscalingGroupID())
+	}
+
+	log.Infof("Operator request: marking instance %s to be removed", instanceID)
+	err = instanceMonitor.MarkManually()
+	instanceMonitor.EmitEvent(events.InstanceMarked, map[string]interface{}{"manual": true}, err)
+	return err
+}
+
+// UnmarkInstance cancels the removal of an instance: it restores its scale-in protection, removes the
+// removal tags and takes it out of maintenance. Not possible once AWS started terminating the instance
+func (y *Watcher) UnmarkInstance(instanceID string) error {
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	instanceMonitor, _, err := y.getOperatorInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if !instanceMonitor.IsMarkedToBeRemoved() {
+		return newConflictError("Instance %s is not marked to be removed", instanceID)
+	}
+	if instanceMonitor.IsQuarantined() {
+		return newConflictError("Instance %s is quarantined. Unquarantine it instead", instanceID)
+	}
+	if instanceMonitor.LifecycleState() == monitor.LifecycleStateTerminatingWait {
+		return newConflictError("Instance %s is already in %s. Its removal can't be cancelled", instanceID,
+			monitor.LifecycleStateTerminatingWait)
+	}
+
+	log.Infof("Operator request: cancelling removal of instance %s", instanceID)
+	return y.cancelRemoval(instanceMonitor, "operator")
+}
+
+// QuarantineInstance pulls an instance out of the cluster keeping it running: it's drained as any other
+// marked instance and, once drained, moved to Standby decrementing the autoscaling group desired capacity
+func (y *Watcher) QuarantineInstance(instanceID string) error {
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	instanceMonitor, autoscalingMonitor, err := y.getOperatorInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if instanceMonitor.IsMarkedToBeRemoved() {
+		return newConflictError("Instance %s is already marked to be removed", instanceID)
+	}
+	if instanceMonitor.LifecycleState() != autoscaling.LifecycleStateInService {
+		return newConflictError("Instance %s is in %s. Only instances %s can be quarantined", instanceID,
+			instanceMonitor.LifecycleState(), autoscaling.LifecycleStateInService)
+	}
+	if y.notebook.isPaused(autoscalingMonitor) {
+		return newConflictError("Removals are paused on autoscaling group %s", *instanceMonitor.AutoscalingGroupID())
+	}
+
+	log.Infof("Operator request: quarantining instance %s", instanceID)
+	err = instanceMonitor.Quarantine()
+	instanceMonitor.EmitEvent(events.InstanceMarked, map[string]interface{}{"quarantine": true}, err)
+	return err
+}
+
+// UnquarantineInstance ends the quarantine of an instance: it's moved out of Standby, if it was already on it,
+// and its removal is cancelled as UnmarkInstance does
+func (y *Watcher) UnquarantineInstance(instanceID string) error {
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	instanceMonitor, _, err := y.getOperatorInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if !instanceMonitor.IsQuarantined() {
+		return newConflictError("Instance %s is not quarantined", instanceID)
+	}
+
+	switch {
+	case instanceMonitor.LifecycleState() == monitor.LifecycleStateStandby:
+		log.Infof("Operator request: moving instance %s out of %s", instanceID, monitor.LifecycleStateStandby)
+		err := instanceMonitor.ExitStandby()
+		instanceMonitor.EmitEvent(events.StandbyExited, nil, err)
+		if err != nil {
+			return err
+		}
+	case instanceMonitor.IsStandbyRequested():
+		return newConflictError("Instance %s is entering %s. Retry once it's on it", instanceID,
+			monitor.LifecycleStateStandby)
+	case instanceMonitor.LifecycleState() != autoscaling.LifecycleStateInService:
+		return newConflictError("Instance %s is in %s. Its quarantine can't be ended", instanceID,
+			instanceMonitor.LifecycleState())
+	}
+
+	log.Infof("Operator request: ending quarantine of instance %s", instanceID)
+	return y.cancelRemoval(instanceMonitor, "operator")
+}
+
+// ForceDestroyInstance destroys a marked instance without waiting for its tasks to finish. If AWS has not
+// moved it to Terminating:Wait yet, it will be destroyed as soon as it does
+func (y *Watcher) ForceDestroyInstance(instanceID string) error {
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	instanceMonitor, autoscalingMonitor, err := y.getOperatorInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if !instanceMonitor.IsMarkedToBeRemoved() {
+		return newConflictError("Instance %s is not marked to be removed", instanceID)
+	}
+	if y.notebook.isPaused(autoscalingMonitor) {
+		return newConflictError("Removals are paused on autoscaling group %s", *instanceMonitor.AutoscalingGroupID())
+	}
+
+	log.Infof("Operator request: forcing destroy of instance %s", instanceID)
+	instanceMonitor.ForceDestroy()
+	err = y.notebook.forceDestroy(autoscalingMonitor, instanceMonitor)
+	instanceMonitor.EmitEvent(events.DestroyForced, nil, err)
+	return err
+}
+
+// cancelRemoval cancels the removal of a marked instance, and takes it out of maintenance
+func (y *Watcher) cancelRemoval(instanceMonitor *monitor.InstanceMonitor, reason string) error {
+
+	err := instanceMonitor.CancelRemoval()
+	if err == nil {
+		err = y.notebook.cancelMaintenance(instanceMonitor)
+	}
+	instanceMonitor.EmitEvent(events.RemovalCancelled, map[string]interface{}{"reason": reason}, err)
+	return err
+}
+
+func (y *Watcher) getOperatorInstance(instanceID string) (*monitor.InstanceMonitor,
+	*monitor.AutoscalingGroupMonitor, error) {
+
+	instanceMonitor, err := y.autoscalingServiceMonitor.GetInstanceByID(instanceID)
+	if err != nil {
+		return nil, nil, &OperatorError{NotFound: true, message: err.Error()}
+	}
+
+	autoscalingMonitor, err := y.autoscalingServiceMonitor.GetAutoscalingGroupMonitorByInstanceID(instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return instanceMonitor, autoscalingMonitor, nil
+}
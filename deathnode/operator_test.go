@@ -0,0 +1,107 @@
+package deathnode
+
+import (
+	"testing"
+
+	"github.com/alanbover/deathnode/aws"
+	"github.com/alanbover/deathnode/mesos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOperator(t *testing.T) {
+
+	Convey("When an operator requests actions over an instance", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":   {"node1", "node2", "node3"},
+				"DescribeInstancesByTag": {"default", "one_undesired_host", "default"},
+				"DescribeAGByName":       {"default", "default"},
+			},
+		}
+		mesosConn := &mesos.ClientMock{
+			Records: map[string]*[]string{
+				"GetMesosFrameworks": {"default", "default"},
+				"GetMesosSlaves":     {"default", "default"},
+				"GetMesosTasks":      {"notasks", "notasks"},
+			},
+		}
+		watcher := newWatcher(testCollectionValues{awsConn: awsConn, mesosConn: mesosConn})
+		watcher.Run()
+		awsConn.FlushMock()
+
+		Convey("unknown instances should return a not found error", func() {
+			err := watcher.MarkInstance("i-unknown")
+			So(err, ShouldNotBeNil)
+			So(err.(*OperatorError).NotFound, ShouldBeTrue)
+		})
+		Convey("instances not marked can't be unmarked or forced", func() {
+			So(watcher.UnmarkInstance("i-34719eb8"), ShouldNotBeNil)
+			So(watcher.ForceDestroyInstance("i-34719eb8"), ShouldNotBeNil)
+		})
+		Convey("marking an instance should tag it as manually marked", func() {
+			So(watcher.MarkInstance("i-34719eb8"), ShouldBeNil)
+			So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 2)
+			So(awsConn.Requests["SetInstanceTag"][1][0], ShouldEqual, "DEATH_NODE_MARK_MANUAL")
+			So(watcher.MarkInstance("i-34719eb8"), ShouldNotBeNil)
+
+			Convey("once drained, it should be terminated keeping the desired capacity", func() {
+				watcher.Run()
+				So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 2)
+				So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldHaveLength, 1)
+				So(awsConn.Requests["TerminateASGInstance"], ShouldResemble, [][]string{{"i-34719eb8", "false"}})
+			})
+			Convey("unmarking it should restore protection and remove the tags", func() {
+				So(watcher.UnmarkInstance("i-34719eb8"), ShouldBeNil)
+				So(awsConn.Requests["SetASGInstanceProtection"], ShouldResemble,
+					[][]string{{"some-Autoscaling-Group", "i-34719eb8"}})
+				So(awsConn.Requests["DeleteInstanceTag"], ShouldResemble, [][]string{
+					{"DEATH_NODE_MARK", "i-34719eb8"},
+					{"DEATH_NODE_MARK_MANUAL", "i-34719eb8"},
+				})
+				instanceMonitor, _ := watcher.autoscalingServiceMonitor.GetInstanceByID("i-34719eb8")
+				So(instanceMonitor.IsMarkedToBeRemoved(), ShouldBeFalse)
+				So(instanceMonitor.IsProtected(), ShouldBeTrue)
+			})
+			Convey("forcing it should terminate it without waiting for the next run", func() {
+				So(watcher.ForceDestroyInstance("i-34719eb8"), ShouldBeNil)
+				So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldHaveLength, 1)
+				So(awsConn.Requests["TerminateASGInstance"], ShouldHaveLength, 1)
+			})
+		})
+		Convey("quarantining an instance should tag it as quarantined", func() {
+			So(watcher.QuarantineInstance("i-34719eb8"), ShouldBeNil)
+			So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 2)
+			So(awsConn.Requests["SetInstanceTag"][1][0], ShouldEqual, "DEATH_NODE_MARK_QUARANTINE")
+			So(watcher.UnmarkInstance("i-34719eb8"), ShouldNotBeNil)
+
+			Convey("once drained, it should be moved to Standby decrementing the desired capacity", func() {
+				watcher.Run()
+				So(awsConn.Requests["RemoveASGInstanceProtection"], ShouldBeNil)
+				So(awsConn.Requests["TerminateASGInstance"], ShouldBeNil)
+				So(awsConn.Requests["EnterStandby"], ShouldResemble, [][]string{
+					{"some-Autoscaling-Group", "i-34719eb8", "true"}})
+				So(watcher.UnquarantineInstance("i-34719eb8"), ShouldNotBeNil)
+
+				Convey("and unquarantining it once in Standby should move it back to service", func() {
+					awsConn.Records = map[string]*[]string{
+						"DescribeAGByName":       {"standby"},
+						"DescribeInstancesByTag": {"default"},
+					}
+					watcher.autoscalingServiceMonitor.Refresh()
+					autoscalingMonitor := watcher.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList()[0]
+					So(autoscalingMonitor.GetNumUndesiredInstances(), ShouldEqual, 0)
+
+					So(watcher.UnquarantineInstance("i-34719eb8"), ShouldBeNil)
+					So(awsConn.Requests["ExitStandby"], ShouldResemble, [][]string{
+						{"some-Autoscaling-Group", "i-34719eb8"}})
+					So(awsConn.Requests["DeleteInstanceTag"], ShouldResemble, [][]string{
+						{"DEATH_NODE_MARK", "i-34719eb8"},
+						{"DEATH_NODE_MARK_QUARANTINE", "i-34719eb8"},
+					})
+					instanceMonitor, _ := watcher.autoscalingServiceMonitor.GetInstanceByID("i-34719eb8")
+					So(instanceMonitor.IsQuarantined(), ShouldBeFalse)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package deathnode
+
+// Global switch freezing all scale-in activity, while lifecycle actions are kept alive
+
+import (
+	"sync"
+
+	"github.com/alanbover/deathnode/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// pauseSwitch stores whether removals are paused globally. It has its own lock, so a pause applies
+// immediately, even in the middle of a run
+type pauseSwitch struct {
+	mutex  sync.Mutex
+	paused bool
+}
+
+// set updates the switch, returning whether it changed
+func (p *pauseSwitch) set(paused bool) bool {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	changed := p.paused != paused
+	p.paused = paused
+	return changed
+}
+
+func (p *pauseSwitch) isPaused() bool {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.paused
+}
+
+// Pause stops tagging new instances and completing lifecycle actions on all autoscaling groups. Instances
+// already in Terminating:Wait keep being heartbeated. source describes who requested it (api, signal...)
+func (y *Watcher) Pause(source string) {
+	y.setPaused(true, source)
+}
+
+// Resume undoes Pause. Autoscaling groups paused by tag stay paused
+func (y *Watcher) Resume(source string) {
+	y.setPaused(false, source)
+}
+
+// IsPaused is true when removals are paused globally
+func (y *Watcher) IsPaused() bool {
+	return y.notebook.pause.isPaused()
+}
+
+func (y *Watcher) setPaused(paused bool, source string) {
+
+	if !y.notebook.pause.set(paused) {
+		return
+	}
+
+	eventType := events.Resumed
+	if paused {
+		eventType = events.Paused
+	}
+	log.Infof("Removals %s on all autoscaling groups (requested by %s)", eventType, source)
+	y.ctx.Emit(events.Event{Type: eventType, Details: map[string]interface{}{"source": source}})
+}
@@ -0,0 +1,200 @@
+package deathnode
+
+// Snapshot of what deathnode currently believes about the monitored autoscaling groups and instances
+
+import (
+	"sync"
+
+	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/monitor"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// PendingDelayDelete means the instance waits for delayDelete since the last destroy on its group
+	PendingDelayDelete = "waiting on delayDelete"
+	// PendingProtectedTasks means the instance is still running tasks from protected frameworks or labels
+	PendingProtectedTasks = "running protected tasks"
+	// PendingNotDrained means the Aurora maintenance on the instance has not reached DRAINED
+	PendingNotDrained = "not drained"
+	// PendingPaused means removals are paused, globally or on the autoscaling group
+	PendingPaused = "removals paused"
+	// PendingNotTerminatingWait means AWS has not yet moved the instance to Terminating:Wait
+	PendingNotTerminatingWait = "not yet " + monitor.LifecycleStateTerminatingWait
+)
+
+// Status stores the state of all the monitored autoscaling groups
+type Status struct {
+	Paused            bool                     `json:"paused"`
+	AutoscalingGroups []AutoscalingGroupStatus `json:"autoscalingGroups"`
+	Orphans           []OrphanStatus           `json:"orphans"`
+}
+
+// OrphanStatus stores an instance tagged to be removed that doesn't belong to any monitored autoscaling
+// group. AutoscalingGroup is the group AWS launched it from, if any
+type OrphanStatus struct {
+	InstanceID       string `json:"instanceId"`
+	IP               string `json:"ip,omitempty"`
+	AutoscalingGroup string `json:"autoscalingGroup,omitempty"`
+}
+
+// AutoscalingGroupStatus stores the state of an autoscaling group and its instances
+type AutoscalingGroupStatus struct {
+	Name               string           `json:"name"`
+	Selector           string           `json:"selector"`
+	DesiredCapacity    int64            `json:"desiredCapacity"`
+	Paused             bool             `json:"paused"`
+	UndesiredInstances int              `json:"undesiredInstances"`
+	Instances          []InstanceStatus `json:"instances"`
+}
+
+// InstanceStatus stores the state of an instance. Drain is only set for instances marked to be removed, and
+// the Terminating:Wait entry time and the seconds left until the AWS lifecycle action global timeout only for
+// the instances in Terminating:Wait
+type InstanceStatus struct {
+	InstanceID                string       `json:"instanceId"`
+	IP                        string       `json:"ip"`
+	LifecycleState            string       `json:"lifecycleState"`
+	Protected                 bool         `json:"protected"`
+	TagRemovalTimestamp       int64        `json:"tagRemovalTimestamp,omitempty"`
+	ManuallyMarked            bool         `json:"manuallyMarked,omitempty"`
+	Quarantined               bool         `json:"quarantined,omitempty"`
+	TerminatingWaitSince      int64        `json:"terminatingWaitSince,omitempty"`
+	LifecycleRemainingSeconds *int64       `json:"lifecycleRemainingSeconds,omitempty"`
+	Drain                     *DrainStatus `json:"drain,omitempty"`
+}
+
+// DrainStatus stores the drain progress of an instance marked to be removed, and the reasons why it has
+// not been destroyed yet
+type DrainStatus struct {
+	MaintenanceMode string   `json:"maintenanceMode,omitempty"`
+	ProtectedTasks  []string `json:"protectedTasks"`
+	PendingReasons  []string `json:"pendingReasons"`
+}
+
+// statusSnapshot stores the last Status published. It has its own lock, so it can be read while a run holds
+// the watcher one
+type statusSnapshot struct {
+	mutex  sync.Mutex
+	status Status
+}
+
+// Status returns a snapshot of the monitored autoscaling groups, as seen at the end of the last run or
+// operator action. The global pause is always the current one
+func (y *Watcher) Status() Status {
+
+	y.snapshot.mutex.Lock()
+	status := y.snapshot.status
+	y.snapshot.mutex.Unlock()
+
+	status.Paused = y.IsPaused()
+	if status.AutoscalingGroups == nil {
+		status.AutoscalingGroups = []AutoscalingGroupStatus{}
+	}
+	if status.Orphans == nil {
+		status.Orphans = []OrphanStatus{}
+	}
+	return status
+}
+
+// publishStatus replaces the snapshot returned by Status. It must be called holding the watcher lock
+func (y *Watcher) publishStatus() {
+
+	status := y.buildStatus()
+
+	y.snapshot.mutex.Lock()
+	defer y.snapshot.mutex.Unlock()
+	y.snapshot.status = status
+}
+
+func (y *Watcher) buildStatus() Status {
+
+	status := Status{Paused: y.IsPaused(), AutoscalingGroups: []AutoscalingGroupStatus{}}
+	for _, autoscalingMonitor := range y.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList() {
+		autoscalingGroupStatus := AutoscalingGroupStatus{
+			Name:               autoscalingMonitor.GetAutoscalingGroupName(),
+			Selector:           autoscalingMonitor.Conf().ID(),
+			DesiredCapacity:    autoscalingMonitor.GetDesiredCapacity(),
+			Paused:             autoscalingMonitor.IsPaused(),
+			UndesiredInstances: autoscalingMonitor.GetNumUndesiredInstances(),
+			Instances:          []InstanceStatus{},
+		}
+
+		for _, instanceMonitor := range autoscalingMonitor.GetAllInstances() {
+			instanceStatus := InstanceStatus{
+				InstanceID:          *instanceMonitor.InstanceID(),
+				IP:                  instanceMonitor.IP(),
+				LifecycleState:      instanceMonitor.LifecycleState(),
+				Protected:           instanceMonitor.IsProtected(),
+				TagRemovalTimestamp: instanceMonitor.TagRemovalTimestamp(),
+				ManuallyMarked:      instanceMonitor.IsManuallyMarked(),
+				Quarantined:         instanceMonitor.IsQuarantined(),
+			}
+			if remaining, ok := y.notebook.lifecycleRemaining(autoscalingMonitor, instanceMonitor); ok {
+				remainingSeconds := int64(remaining.Seconds())
+				instanceStatus.TerminatingWaitSince = instanceMonitor.TerminatingWaitSince().Unix()
+				instanceStatus.LifecycleRemainingSeconds = &remainingSeconds
+			}
+			if instanceMonitor.IsMarkedToBeRemoved() {
+				instanceStatus.Drain = y.notebook.drainStatus(autoscalingMonitor, instanceMonitor)
+			}
+			autoscalingGroupStatus.Instances = append(autoscalingGroupStatus.Instances, instanceStatus)
+		}
+
+		status.AutoscalingGroups = append(status.AutoscalingGroups, autoscalingGroupStatus)
+	}
+
+	status.Orphans = []OrphanStatus{}
+	for _, orphan := range y.notebook.orphans {
+		status.Orphans = append(status.Orphans, OrphanStatus{
+			InstanceID:       *orphan.InstanceId,
+			IP:               aws.StringValue(orphan.PrivateIpAddress),
+			AutoscalingGroup: instanceTag(orphan, autoscalingGroupNameTag),
+		})
+	}
+
+	return status
+}
+
+// drainStatus evaluates the same conditions as destroyInstanceAttempt, returning the ones preventing the
+// instance from being destroyed
+func (n *Notebook) drainStatus(autoscalingMonitor *monitor.AutoscalingGroupMonitor,
+	instanceMonitor *monitor.InstanceMonitor) *DrainStatus {
+
+	settings := autoscalingMonitor.Settings()
+	mesosMonitor := n.mesosMonitor.WithProtection(settings.ProtectedFrameworks, settings.ProtectedTasksLabels)
+
+	drainStatus := &DrainStatus{
+		ProtectedTasks: mesosMonitor.GetProtectedTasks(instanceMonitor.IP()),
+		PendingReasons: []string{},
+	}
+
+	if n.isPaused(autoscalingMonitor) {
+		drainStatus.PendingReasons = append(drainStatus.PendingReasons, PendingPaused)
+	}
+
+	forced := instanceMonitor.IsForcedDestroy()
+	if !forced && n.shouldWaitForNextDestroy(autoscalingMonitor) {
+		drainStatus.PendingReasons = append(drainStatus.PendingReasons, PendingDelayDelete)
+	}
+
+	drained := false
+	if n.ctx.Conf.AuroraURL != "" {
+		drainStatus.MaintenanceMode = n.auroraMonitor.MaintenanceMode(instanceMonitor.IP())
+		drained = n.auroraMonitor.IsDrained(instanceMonitor.IP())
+	}
+
+	if !forced && !drained && len(drainStatus.ProtectedTasks) > 0 {
+		drainStatus.PendingReasons = append(drainStatus.PendingReasons, PendingProtectedTasks)
+		if n.ctx.Conf.AuroraURL != "" {
+			drainStatus.PendingReasons = append(drainStatus.PendingReasons, PendingNotDrained)
+		}
+	}
+
+	if settings.RemovalMode != context.RemovalDetach && !instanceMonitor.IsQuarantined() &&
+		instanceMonitor.LifecycleState() != monitor.LifecycleStateTerminatingWait {
+		drainStatus.PendingReasons = append(drainStatus.PendingReasons, PendingNotTerminatingWait)
+	}
+
+	return drainStatus
+}
@@ -3,7 +3,12 @@ package deathnode
 // Given an autoscaling group, decides which is/are the best agent/s to kill
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
 	"github.com/alanbover/deathnode/monitor"
 	log "github.com/sirupsen/logrus"
 )
@@ -14,11 +19,21 @@ type Watcher struct {
 	mesosMonitor              *monitor.MesosMonitor
 	auroraMonitor             *monitor.AuroraMonitor
 	autoscalingServiceMonitor *monitor.AutoscalingServiceMonitor
-	constraints               []constraint
-	recommender               recommender
+	policies                  map[string]*removalPolicy
+	mutex                     sync.Mutex
+	health                    health
+	snapshot                  statusSnapshot
 	ctx                       *context.ApplicationContext
 }
 
+// removalPolicy stores the constraints and the recommender used to pick instances on an autoscaling group
+type removalPolicy struct {
+	constraints     []constraint
+	constraintNames []string
+	recommender     recommender
+	recommenderName string
+}
+
 // NewWatcher returns a new Watcher object
 func NewWatcher(ctx *context.ApplicationContext) *Watcher {
 
@@ -26,16 +41,7 @@ func NewWatcher(ctx *context.ApplicationContext) *Watcher {
 	mesosMonitor := monitor.NewMesosMonitor(ctx)
 	auroraMonitor := monitor.NewAuroraMonitor(ctx)
 
-	constraints := []constraint{}
-	for _, constraint := range ctx.Conf.ConstraintsType {
-		newConstraint, err := newConstraint(constraint)
-		if err != nil {
-			log.Fatal(err)
-		}
-		constraints = append(constraints, newConstraint)
-	}
-
-	recommender, err := newRecommender(ctx.Conf.RecommenderType)
+	policies, err := newRemovalPolicies(&ctx.Conf)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -44,27 +50,99 @@ func NewWatcher(ctx *context.ApplicationContext) *Watcher {
 		notebook:                  NewNotebook(ctx, autoscalingServiceMonitor, mesosMonitor, auroraMonitor),
 		mesosMonitor:              mesosMonitor,
 		auroraMonitor:             auroraMonitor,
-		constraints:               constraints,
-		recommender:               recommender,
+		policies:                  policies,
 		autoscalingServiceMonitor: autoscalingServiceMonitor,
 		ctx: ctx,
 	}
 }
 
+// newRemovalPolicies returns the removalPolicy for every autoscaling group selector, indexed by selector ID
+func newRemovalPolicies(conf *context.ApplicationConf) (map[string]*removalPolicy, error) {
+
+	policies := map[string]*removalPolicy{}
+	for _, selector := range conf.Selectors() {
+		settings := conf.Settings(selector)
+
+		constraints := []constraint{}
+		for _, constraint := range settings.ConstraintsType {
+			newConstraint, err := newConstraint(constraint)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, newConstraint)
+		}
+
+		recommender, err := newRecommender(settings.RecommenderType)
+		if err != nil {
+			return nil, err
+		}
+
+		policies[selector.ID()] = &removalPolicy{
+			constraints:     constraints,
+			constraintNames: settings.ConstraintsType,
+			recommender:     recommender,
+			recommenderName: settings.RecommenderType,
+		}
+	}
+
+	return policies, nil
+}
+
 // TagInstancesToBeRemoved finds, if any instances to be removed for an autoscaling group, the best instances to
 // kill and tags them to be removed
 func (y *Watcher) TagInstancesToBeRemoved(autoscalingMonitor *monitor.AutoscalingGroupMonitor) {
 
+	autoscalingGroupName := autoscalingMonitor.GetAutoscalingGroupName()
 	numUndesiredInstances := autoscalingMonitor.GetNumUndesiredInstances()
-	log.WithField("autoscaling_group", autoscalingMonitor.GetAutoscalingGroupName()).Debugf("Undesired Mesos Agents: %d", numUndesiredInstances)
+	log.WithField("autoscaling_group", autoscalingGroupName).Debugf("Undesired Mesos Agents: %d", numUndesiredInstances)
+	y.ctx.Emit(events.Event{
+		Type:             events.UndesiredCount,
+		AutoscalingGroup: autoscalingGroupName,
+		Details: map[string]interface{}{
+			"undesired":       numUndesiredInstances,
+			"desiredCapacity": autoscalingMonitor.GetDesiredCapacity(),
+		},
+	})
+
+	if y.notebook.isPaused(autoscalingMonitor) {
+		log.WithField("autoscaling_group", autoscalingGroupName).Debug("Removals paused. No instance will be tagged")
+		return
+	}
+
+	policy, ok := y.policies[autoscalingMonitor.Conf().ID()]
+	if !ok {
+		log.Errorf("No removal policy found for autoscaling group %s", autoscalingMonitor.GetAutoscalingGroupName())
+		return
+	}
+
+	settings := autoscalingMonitor.Settings()
+	mesosMonitor := y.mesosMonitor.WithProtection(settings.ProtectedFrameworks, settings.ProtectedTasksLabels)
 
 	for removedInstances := 0; removedInstances < numUndesiredInstances; removedInstances++ {
 
 		allowedInstances := autoscalingMonitor.GetInstances()
-		for _, constraint := range y.constraints {
-			allowedInstances = constraint.filter(allowedInstances, y.mesosMonitor)
+		y.ctx.Emit(events.Event{
+			Type:             events.Candidates,
+			AutoscalingGroup: autoscalingGroupName,
+			Details:          map[string]interface{}{"instances": instanceIDs(allowedInstances)},
+		})
+
+		for i, constraint := range policy.constraints {
+			filteredInstances := constraint.filter(allowedInstances, mesosMonitor)
+			y.ctx.Emit(events.Event{
+				Type:             events.ConstraintFiltered,
+				AutoscalingGroup: autoscalingGroupName,
+				Details: map[string]interface{}{
+					"constraint": policy.constraintNames[i],
+					"removed":    removedInstanceIDs(allowedInstances, filteredInstances),
+				},
+			})
+			allowedInstances = filteredInstances
 		}
-		bestInstance := y.recommender.find(allowedInstances)
+		bestInstance := policy.recommender.find(allowedInstances)
+		bestInstance.EmitEvent(events.RecommenderChoice, map[string]interface{}{
+			"recommender": policy.recommenderName,
+		}, nil)
 
 		log.Debugf("Tagging instance %s for removal", *bestInstance.InstanceID())
 		if err := bestInstance.TagToBeRemoved(); err != nil {
@@ -72,6 +150,7 @@ func (y *Watcher) TagInstancesToBeRemoved(autoscalingMonitor *monitor.Autoscalin
 			log.Error(err)
 			break
 		}
+		bestInstance.EmitEvent(events.InstanceMarked, nil, nil)
 	}
 }
 
@@ -87,17 +166,117 @@ func (y *Watcher) DestroyInstancesAttempt() {
 // Run starts the process of check instances to be killed and try to kill them for all Autoscalings
 func (y *Watcher) Run() {
 
+	if err := y.RunUntil(time.Time{}); err != nil {
+		log.Error(err)
+	}
+}
+
+// RunUntil works as Run, but stops before starting a new step once the deadline has been exceeded.
+// A zero deadline means no deadline. Steps already started are never interrupted
+func (y *Watcher) RunUntil(deadline time.Time) error {
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
 	log.Debug("New check triggered")
 
-	y.autoscalingServiceMonitor.Refresh()
-	y.mesosMonitor.Refresh()
+	refreshResults := map[string]error{
+		ComponentAWS:   y.autoscalingServiceMonitor.Refresh(),
+		ComponentMesos: y.mesosMonitor.Refresh(),
+	}
 	if y.ctx.Conf.AuroraURL != "" {
-		y.auroraMonitor.Refresh()
+		refreshResults[ComponentAurora] = y.auroraMonitor.Refresh()
+	}
+	y.health.setRefreshResults(refreshResults)
+	y.endCancelledMaintenances()
+	if refreshResults[ComponentMesos] == nil {
+		y.completeLaunches()
 	}
 
 	for _, autoscalingGroup := range y.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList() {
+		if err := y.checkDeadline(deadline); err != nil {
+			return err
+		}
 		y.TagInstancesToBeRemoved(autoscalingGroup)
 	}
 
+	if err := y.checkDeadline(deadline); err != nil {
+		return err
+	}
 	y.DestroyInstancesAttempt()
+	y.health.setRunCompleted(y.ctx.Clock.Now())
+	return nil
+}
+
+// Reload validates a new configuration and, if it's valid, swaps it for the current one, rebuilding the
+// constraints and recommenders. Monitors and caches are kept, so no state is lost
+func (y *Watcher) Reload(conf context.ApplicationConf) error {
+
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	policies, err := newRemovalPolicies(&conf)
+	if err != nil {
+		return err
+	}
+
+	y.mutex.Lock()
+	defer y.mutex.Unlock()
+	defer y.publishStatus()
+
+	y.ctx.Conf = conf
+	y.policies = policies
+	y.autoscalingServiceMonitor.Reload()
+	return nil
+}
+
+// endCancelledMaintenances ends the Aurora maintenance of the instances whose removal was cancelled on
+// refresh. On Mesos, the maintenance is scheduled again without them on DestroyInstancesAttempt
+func (y *Watcher) endCancelledMaintenances() {
+
+	for _, autoscalingMonitor := range y.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList() {
+		for _, instanceMonitor := range autoscalingMonitor.TakeCancelledRemovals() {
+			if y.ctx.Conf.AuroraURL != "" {
+				if err := y.notebook.endMaintenance(instanceMonitor); err != nil {
+					log.Errorf("Unable to end maintenance of instance %s: %s", *instanceMonitor.InstanceID(), err)
+				}
+			}
+		}
+	}
+}
+
+func instanceIDs(instanceMonitors []*monitor.InstanceMonitor) []string {
+
+	ids := []string{}
+	for _, instanceMonitor := range instanceMonitors {
+		ids = append(ids, *instanceMonitor.InstanceID())
+	}
+	return ids
+}
+
+// removedInstanceIDs returns the IDs of the instances in before that are not in after
+func removedInstanceIDs(before, after []*monitor.InstanceMonitor) []string {
+
+	kept := map[string]bool{}
+	for _, instanceMonitor := range after {
+		kept[*instanceMonitor.InstanceID()] = true
+	}
+
+	ids := []string{}
+	for _, instanceMonitor := range before {
+		if !kept[*instanceMonitor.InstanceID()] {
+			ids = append(ids, *instanceMonitor.InstanceID())
+		}
+	}
+	return ids
+}
+
+func (y *Watcher) checkDeadline(deadline time.Time) error {
+
+	if !deadline.IsZero() && y.ctx.Clock.Now().After(deadline) {
+		return fmt.Errorf("Run deadline %v exceeded. Skipping the rest of the iteration", deadline)
+	}
+	return nil
 }
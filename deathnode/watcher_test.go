@@ -3,11 +3,14 @@ package deathnode
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
 	"github.com/alanbover/deathnode/mesos"
 	"github.com/benbjohnson/clock"
+	. "github.com/smartystreets/goconvey/convey"
 )
 
 type testCollectionValues struct {
@@ -234,6 +237,55 @@ func TestWatcher(t *testing.T) {
 	}
 }
 
+func TestWatcherReload(t *testing.T) {
+
+	Convey("When reloading the watcher configuration", t, func() {
+		watcher := newWatcher(testCollectionValues{
+			awsConn: &aws.ConnectionMock{
+				Records: map[string]*[]string{
+					"DescribeInstanceById":   {"node1", "node2", "node3"},
+					"DescribeInstancesByTag": {"default"},
+					"DescribeAGByName":       {"default"},
+				},
+			},
+			mesosConn: &mesos.ClientMock{
+				Records: map[string]*[]string{
+					"GetMesosFrameworks": {"default"},
+					"GetMesosSlaves":     {"default"},
+					"GetMesosTasks":      {"default"},
+				},
+			},
+		})
+		watcher.Run()
+		autoscalingMonitor := watcher.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList()[0]
+		instanceMonitor, _ := watcher.autoscalingServiceMonitor.GetInstanceByID("i-34719eb8")
+
+		Convey("an invalid configuration should be rejected, keeping the current one", func() {
+			conf := watcher.ctx.Conf
+			conf.ConstraintsType = []string{"noExistingConstraint"}
+			So(watcher.Reload(conf), ShouldNotBeNil)
+			So(watcher.ctx.Conf.ConstraintsType[0], ShouldEqual, "noConstraint")
+			So(watcher.policies, ShouldContainKey, "some-Autoscaling-Group")
+		})
+		Convey("a valid configuration should rebuild the policies keeping the monitors", func() {
+			conf := watcher.ctx.Conf
+			conf.AutoscalingGroupPrefixes = []string{}
+			conf.AutoscalingGroups = []*context.AutoscalingGroupConf{
+				{Regexp: "^some-.*$", RecommenderType: "firstAvailableAgent"},
+			}
+			So(watcher.Reload(conf), ShouldBeNil)
+			So(watcher.policies, ShouldHaveLength, 1)
+			So(watcher.policies["regexp:^some-.*$"].recommender, ShouldHaveSameTypeAs, &firstAvailableAgent{})
+
+			reloadedMonitor := watcher.autoscalingServiceMonitor.GetAutoscalingGroupMonitorsList()[0]
+			So(reloadedMonitor, ShouldPointTo, autoscalingMonitor)
+			So(reloadedMonitor.Conf().ID(), ShouldEqual, "regexp:^some-.*$")
+			reloadedInstance, _ := watcher.autoscalingServiceMonitor.GetInstanceByID("i-34719eb8")
+			So(reloadedInstance, ShouldPointTo, instanceMonitor)
+		})
+	})
+}
+
 func newWatcher(testValues testCollectionValues) *Watcher {
 
 	ctx := &context.ApplicationContext{
@@ -262,3 +314,127 @@ func runWatcher(testValues testCollectionValues) {
 		watcher.Run()
 	}
 }
+
+func TestWatcherEvents(t *testing.T) {
+
+	Convey("When the watcher tags an instance to be removed", t, func() {
+		watcher := newWatcher(testCollectionValues{
+			awsConn: &aws.ConnectionMock{
+				Records: map[string]*[]string{
+					"DescribeInstanceById":   {"node1", "node2", "node3"},
+					"DescribeInstancesByTag": {"one_undesired_host", "one_undesired_host"},
+					"DescribeAGByName":       {"one_undesired_host"},
+				},
+			},
+			mesosConn: &mesos.ClientMock{
+				Records: map[string]*[]string{
+					"GetMesosFrameworks": {"default"},
+					"GetMesosSlaves":     {"default"},
+					"GetMesosTasks":      {"notasks"},
+				},
+			},
+		})
+		recorder := &events.Recorder{}
+		watcher.ctx.Events = recorder
+		watcher.Run()
+
+		Convey("it should emit every decision taken", func() {
+			So(recorder.OfType(events.UndesiredCount), ShouldHaveLength, 1)
+			So(recorder.OfType(events.UndesiredCount)[0].Details["undesired"], ShouldEqual, 1)
+			So(recorder.OfType(events.Candidates), ShouldHaveLength, 1)
+			So(recorder.OfType(events.ConstraintFiltered), ShouldHaveLength, 2)
+			So(recorder.OfType(events.RecommenderChoice), ShouldHaveLength, 1)
+			So(recorder.OfType(events.RecommenderChoice)[0].InstanceID, ShouldEqual, "i-34719eb8")
+			So(recorder.OfType(events.TagApplied), ShouldHaveLength, 1)
+			So(recorder.OfType(events.InstanceMarked), ShouldHaveLength, 1)
+			So(recorder.OfType(events.ProtectionRemoved), ShouldHaveLength, 1)
+			So(recorder.OfType(events.MaintenanceScheduled), ShouldHaveLength, 1)
+			So(recorder.OfType(events.DrainStarted), ShouldHaveLength, 1)
+			So(recorder.OfType(events.Error), ShouldBeEmpty)
+		})
+		Convey("the maintenance should only be reported the first time it's scheduled", func() {
+			watcher.notebook.DestroyInstancesAttempt()
+			So(recorder.OfType(events.MaintenanceScheduled), ShouldHaveLength, 1)
+			So(recorder.OfType(events.DrainStarted), ShouldHaveLength, 1)
+		})
+		Convey("every event should be timestamped", func() {
+			for _, event := range recorder.Events {
+				So(event.Timestamp.IsZero(), ShouldBeFalse)
+			}
+		})
+	})
+}
+
+func TestWatcherPaused(t *testing.T) {
+
+	Convey("When removals are paused and an autoscaling group has one undesired instance", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":   {"node1", "node2", "node3"},
+				"DescribeInstancesByTag": {"default", "default"},
+				"DescribeAGByName":       {"one_undesired_host", "one_undesired_host"},
+			},
+		}
+		watcher := newWatcher(testCollectionValues{
+			awsConn: awsConn,
+			mesosConn: &mesos.ClientMock{
+				Records: map[string]*[]string{
+					"GetMesosFrameworks": {"default", "default"},
+					"GetMesosSlaves":     {"default", "default"},
+					"GetMesosTasks":      {"notasks", "notasks"},
+				},
+			},
+		})
+		watcher.Pause("test")
+		watcher.Run()
+
+		Convey("no instance should be tagged", func() {
+			So(watcher.IsPaused(), ShouldBeTrue)
+			So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+		})
+		Convey("once resumed, the undesired instance should be tagged", func() {
+			watcher.Resume("test")
+			watcher.Run()
+			So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestStatusDuringRun(t *testing.T) {
+
+	Convey("When a run holds the watcher lock", t, func() {
+		watcher := newWatcher(testCollectionValues{
+			awsConn: &aws.ConnectionMock{
+				Records: map[string]*[]string{
+					"DescribeInstanceById":   {"node1", "node2", "node3"},
+					"DescribeInstancesByTag": {"one_undesired_host"},
+					"DescribeAGByName":       {"one_undesired_host"},
+				},
+			},
+			mesosConn: &mesos.ClientMock{
+				Records: map[string]*[]string{
+					"GetMesosFrameworks": {"default"},
+					"GetMesosSlaves":     {"default"},
+					"GetMesosTasks":      {"notasks"},
+				},
+			},
+		})
+		watcher.Run()
+
+		watcher.mutex.Lock()
+		defer watcher.mutex.Unlock()
+
+		Convey("the status of the last run should still be returned", func() {
+			statuses := make(chan Status, 1)
+			go func() { statuses <- watcher.Status() }()
+
+			select {
+			case status := <-statuses:
+				So(status.AutoscalingGroups, ShouldHaveLength, 1)
+				So(status.AutoscalingGroups[0].Instances, ShouldHaveLength, 3)
+			case <-time.After(time.Second):
+				t.Error("Status blocked by the watcher lock")
+			}
+		})
+	})
+}
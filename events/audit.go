@@ -0,0 +1,56 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StdoutAuditLog is the audit log path that writes the events to stdout
+const StdoutAuditLog = "-"
+
+// AuditLog is a Sink writing every event as a JSON line
+type AuditLog struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewAuditLog returns an AuditLog writing to writer
+func NewAuditLog(writer io.Writer) *AuditLog {
+	return &AuditLog{writer: writer}
+}
+
+// OpenAuditLog returns an AuditLog appending to the file in path, or writing to stdout if path is "-"
+func OpenAuditLog(path string) (*AuditLog, error) {
+
+	if path == StdoutAuditLog {
+		return NewAuditLog(os.Stdout), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open audit log %s: %s", path, err)
+	}
+	return NewAuditLog(file), nil
+}
+
+// Emit writes the event as a single JSON line
+func (a *AuditLog) Emit(event Event) {
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Warnf("Unable to encode audit event %s: %s", event.Type, err)
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, err := a.writer.Write(append(line, '\n')); err != nil {
+		log.Warnf("Unable to write audit event %s: %s", event.Type, err)
+	}
+}
@@ -0,0 +1,40 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuditLog(t *testing.T) {
+
+	Convey("When emitting events to the audit log", t, func() {
+		var buffer bytes.Buffer
+		bus := NewBus(NewAuditLog(&buffer))
+
+		bus.Emit(Event{
+			Timestamp:        time.Unix(1500000000, 0).UTC(),
+			Type:             TagApplied,
+			AutoscalingGroup: "some-Autoscaling-Group",
+			InstanceID:       "i-34719eb8",
+			IP:               "10.0.0.2",
+		})
+		bus.Emit(Event{Type: Error, Error: "failed", Details: map[string]interface{}{"action": DrainStarted}})
+
+		Convey("every event should be written as a JSON line", func() {
+			lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+			So(lines, ShouldHaveLength, 2)
+			So(lines[0], ShouldEqual, `{"timestamp":"2017-07-14T02:40:00Z","type":"tag_applied",`+
+				`"autoscalingGroup":"some-Autoscaling-Group","instanceId":"i-34719eb8","ip":"10.0.0.2"}`)
+
+			var event Event
+			So(json.Unmarshal([]byte(lines[1]), &event), ShouldBeNil)
+			So(event.Error, ShouldEqual, "failed")
+			So(event.Details["action"], ShouldEqual, DrainStarted)
+		})
+	})
+}
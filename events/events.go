@@ -0,0 +1,101 @@
+package events
+
+// Structured events describing every decision taken by deathnode
+
+import (
+	"time"
+)
+
+// Event types
+const (
+	UndesiredCount       = "undesired_count"
+	Candidates           = "candidates"
+	ConstraintFiltered   = "constraint_filtered"
+	RecommenderChoice    = "recommender_choice"
+	InstanceMarked       = "instance_marked"
+	TagApplied           = "tag_applied"
+	ProtectionRemoved    = "protection_removed"
+	MaintenanceScheduled = "maintenance_scheduled"
+	DrainStarted         = "drain_started"
+	LifecycleHeartbeat   = "lifecycle_heartbeat"
+	LifecycleNotified    = "lifecycle_notified"
+	LifecycleCompleted   = "lifecycle_completed"
+	LifecycleAbandoned   = "lifecycle_abandoned"
+	DrainFailed          = "drain_failed"
+	DeadlineAlert        = "deadline_alert"
+	TerminationAdopted   = "termination_adopted"
+	TerminationRequested = "termination_requested"
+	InstanceDetached     = "instance_detached"
+	InstanceTerminated   = "instance_terminated"
+	StandbyEntered       = "standby_entered"
+	StandbyExited        = "standby_exited"
+	RemovalCancelled     = "removal_cancelled"
+	DestroyForced        = "destroy_forced"
+	Paused               = "paused"
+	Resumed              = "resumed"
+	OrphanFound          = "orphan_found"
+	LaunchCompleted      = "launch_completed"
+	LaunchAbandoned      = "launch_abandoned"
+	Error                = "error"
+)
+
+// Event is a single deathnode decision or action
+type Event struct {
+	Timestamp        time.Time              `json:"timestamp"`
+	Type             string                 `json:"type"`
+	AutoscalingGroup string                 `json:"autoscalingGroup,omitempty"`
+	InstanceID       string                 `json:"instanceId,omitempty"`
+	IP               string                 `json:"ip,omitempty"`
+	Details          map[string]interface{} `json:"details,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+}
+
+// Sink receives the events emitted by deathnode
+type Sink interface {
+	Emit(event Event)
+}
+
+// Bus forwards every event to all its sinks
+type Bus struct {
+	sinks []Sink
+}
+
+// NewBus returns a Bus forwarding the events to the given sinks
+func NewBus(sinks ...Sink) *Bus {
+	return &Bus{sinks: sinks}
+}
+
+// Subscribe adds a sink to the bus. It's not safe to call it while events are being emitted
+func (b *Bus) Subscribe(sink Sink) {
+	b.sinks = append(b.sinks, sink)
+}
+
+// Emit forwards the event to all the sinks
+func (b *Bus) Emit(event Event) {
+
+	for _, sink := range b.sinks {
+		sink.Emit(event)
+	}
+}
+
+// Recorder is a Sink storing the events in memory, for testing purposes
+type Recorder struct {
+	Events []Event
+}
+
+// Emit stores the event
+func (r *Recorder) Emit(event Event) {
+	r.Events = append(r.Events, event)
+}
+
+// OfType returns the recorded events of the given type
+func (r *Recorder) OfType(eventType string) []Event {
+
+	events := []Event{}
+	for _, event := range r.Events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
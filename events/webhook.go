@@ -0,0 +1,307 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/alanbover/deathnode/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookRetries   = 3
+	defaultWebhookQueueSize = 100
+	defaultWebhookTimeout   = 10
+	defaultWebhookBackoff   = time.Second
+)
+
+// WebhookConf stores the settings for an outgoing webhook. Body is a text/template rendered with the
+// Event, with a json function to quote values, i.e: {"text": {{json .InstanceID}}}. When empty, the
+// Event is sent as JSON. An empty Events list sends all the event types
+type WebhookConf struct {
+	Name           string            `json:"name"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+	Events         []string          `json:"events"`
+	Retries        *int              `json:"retries"`
+	QueueSize      int               `json:"queueSize"`
+	TimeoutSeconds int               `json:"timeoutSeconds"`
+}
+
+// Webhook is a Sink posting the events to an URL. Events are queued and sent in the background, so a
+// slow receiver never blocks the caller. Events emitted while the queue is full are dropped
+type Webhook struct {
+	conf    WebhookConf
+	body    *template.Template
+	events  map[string]bool
+	queue   chan Event
+	client  *http.Client
+	backoff time.Duration
+	mutex   sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+var webhookEvents = metrics.DefaultRegistry.NewCounter("deathnode_webhook_events_total",
+	"Events sent to webhooks, by result: delivered, failed or dropped.", "webhook", "result")
+
+// Validate checks that the webhook has a valid URL and body template, setting its defaults
+func (c *WebhookConf) Validate() error {
+
+	parsedURL, err := url.Parse(c.URL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return fmt.Errorf("Invalid url %q for webhook", c.URL)
+	}
+
+	if _, err := newBodyTemplate(c.Body); err != nil {
+		return fmt.Errorf("Invalid body template for webhook %s: %s", c.URL, err)
+	}
+
+	if c.Name == "" {
+		c.Name = parsedURL.Host
+	}
+	if c.Retries == nil {
+		retries := defaultWebhookRetries
+		c.Retries = &retries
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultWebhookQueueSize
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = defaultWebhookTimeout
+	}
+	return nil
+}
+
+// NewWebhook returns a Webhook for conf. Start must be called for the events to be sent
+func NewWebhook(conf WebhookConf) (*Webhook, error) {
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	body, _ := newBodyTemplate(conf.Body)
+	events := map[string]bool{}
+	for _, eventType := range conf.Events {
+		events[eventType] = true
+	}
+
+	return &Webhook{
+		conf:    conf,
+		body:    body,
+		events:  events,
+		queue:   make(chan Event, conf.QueueSize),
+		client:  &http.Client{Timeout: time.Duration(conf.TimeoutSeconds) * time.Second},
+		backoff: defaultWebhookBackoff,
+	}, nil
+}
+
+func newBodyTemplate(body string) (*template.Template, error) {
+
+	if body == "" {
+		return nil, nil
+	}
+
+	return template.New("body").Funcs(template.FuncMap{
+		"json": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	}).Parse(body)
+}
+
+// Emit queues the event, if its type is selected by the webhook. It never blocks
+func (w *Webhook) Emit(event Event) {
+
+	if len(w.events) > 0 && !w.events[event.Type] {
+		return
+	}
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.stopped {
+		webhookEvents.Inc(w.conf.Name, "dropped")
+		return
+	}
+
+	select {
+	case w.queue <- event:
+	default:
+		webhookEvents.Inc(w.conf.Name, "dropped")
+		log.Warnf("Webhook %s queue is full. Dropping %s event", w.conf.Name, event.Type)
+	}
+}
+
+// Start sends the queued events in the background, until Stop is called
+func (w *Webhook) Start() {
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for event := range w.queue {
+			w.send(event)
+		}
+	}()
+}
+
+// Stop waits for the queued events to be sent. Events emitted after it are dropped
+func (w *Webhook) Stop() {
+
+	w.mutex.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.queue)
+	}
+	w.mutex.Unlock()
+	w.wg.Wait()
+}
+
+// send posts the event, retrying with exponential backoff
+func (w *Webhook) send(event Event) {
+
+	body, err := w.render(event)
+	if err != nil {
+		webhookEvents.Inc(w.conf.Name, "failed")
+		log.Errorf("Unable to render webhook %s body for %s event: %s", w.conf.Name, event.Type, err)
+		return
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		if err = w.post(body); err == nil {
+			webhookEvents.Inc(w.conf.Name, "delivered")
+			return
+		}
+
+		if attempt >= *w.conf.Retries {
+			break
+		}
+		log.Debugf("Webhook %s failed, retrying in %s: %s", w.conf.Name, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	webhookEvents.Inc(w.conf.Name, "failed")
+	log.Errorf("Unable to send %s event to webhook %s: %s", event.Type, w.conf.Name, err)
+}
+
+func (w *Webhook) render(event Event) ([]byte, error) {
+
+	if w.body == nil {
+		return json.Marshal(event)
+	}
+
+	var body bytes.Buffer
+	if err := w.body.Execute(&body, event); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func (w *Webhook) post(body []byte) error {
+
+	request, err := http.NewRequest("POST", w.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range w.conf.Headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+// WebhookSet is a Sink forwarding the events to a set of webhooks, which can be replaced while running
+type WebhookSet struct {
+	mutex    sync.Mutex
+	confs    []WebhookConf
+	webhooks []*Webhook
+}
+
+// NewWebhookSet returns a WebhookSet with the webhooks for confs already started
+func NewWebhookSet(confs []WebhookConf) (*WebhookSet, error) {
+
+	webhookSet := &WebhookSet{}
+	if err := webhookSet.Reload(confs); err != nil {
+		return nil, err
+	}
+	return webhookSet, nil
+}
+
+// Reload replaces the webhooks with new ones for confs, once the previous ones have sent their queued
+// events. If any of confs is invalid, the current webhooks are kept. Unchanged confs are ignored
+func (s *WebhookSet) Reload(confs []WebhookConf) error {
+
+	s.mutex.Lock()
+	if s.webhooks != nil && reflect.DeepEqual(confs, s.confs) {
+		s.mutex.Unlock()
+		return nil
+	}
+
+	webhooks := []*Webhook{}
+	for _, conf := range confs {
+		webhook, err := NewWebhook(conf)
+		if err != nil {
+			s.mutex.Unlock()
+			return err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	for _, webhook := range webhooks {
+		webhook.Start()
+	}
+
+	previousWebhooks := s.webhooks
+	s.confs = confs
+	s.webhooks = webhooks
+	s.mutex.Unlock()
+
+	for _, webhook := range previousWebhooks {
+		webhook.Stop()
+	}
+	return nil
+}
+
+// Emit queues the event on every webhook
+func (s *WebhookSet) Emit(event Event) {
+
+	s.mutex.Lock()
+	webhooks := s.webhooks
+	s.mutex.Unlock()
+
+	for _, webhook := range webhooks {
+		webhook.Emit(event)
+	}
+}
+
+// Stop waits for the queued events of every webhook to be sent
+func (s *WebhookSet) Stop() {
+
+	s.mutex.Lock()
+	webhooks := s.webhooks
+	s.webhooks = []*Webhook{}
+	s.mutex.Unlock()
+
+	for _, webhook := range webhooks {
+		webhook.Stop()
+	}
+}
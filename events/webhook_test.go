@@ -0,0 +1,132 @@
+package events
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type receiver struct {
+	mutex    sync.Mutex
+	failures int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header)
+}
+
+func TestWebhook(t *testing.T) {
+
+	Convey("When sending events to a webhook", t, func() {
+		receiver := &receiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		retries := 2
+		webhook, err := NewWebhook(WebhookConf{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+			Body:    `{"text": {{json (printf "Draining %s (%s)" .InstanceID .IP)}}}`,
+			Events:  []string{DrainStarted},
+			Retries: &retries,
+		})
+		So(err, ShouldBeNil)
+		webhook.backoff = time.Millisecond
+
+		Convey("it should post the templated body with the headers for the selected events", func() {
+			webhook.Start()
+			webhook.Emit(Event{Type: TagApplied, InstanceID: "i-34719eb8"})
+			webhook.Emit(Event{Type: DrainStarted, InstanceID: "i-34719eb8", IP: "10.0.0.2"})
+			webhook.Stop()
+
+			So(receiver.bodies, ShouldResemble, []string{`{"text": "Draining i-34719eb8 (10.0.0.2)"}`})
+			So(receiver.headers[0].Get("Authorization"), ShouldEqual, "Bearer token")
+			So(receiver.headers[0].Get("Content-Type"), ShouldEqual, "application/json")
+		})
+		Convey("it should retry failed deliveries", func() {
+			receiver.failures = 2
+			webhook.Start()
+			webhook.Emit(Event{Type: DrainStarted})
+			webhook.Stop()
+
+			So(receiver.bodies, ShouldHaveLength, 1)
+			So(webhookEvents.Value(webhook.conf.Name, "delivered"), ShouldBeGreaterThan, 0)
+		})
+		Convey("it should give up after the configured retries", func() {
+			receiver.failures = 3
+			failed := webhookEvents.Value(webhook.conf.Name, "failed")
+			webhook.Start()
+			webhook.Emit(Event{Type: DrainStarted})
+			webhook.Stop()
+
+			So(receiver.bodies, ShouldBeEmpty)
+			So(webhookEvents.Value(webhook.conf.Name, "failed"), ShouldEqual, failed+1)
+		})
+		Convey("it should never block when the queue is full", func() {
+			dropped := webhookEvents.Value(webhook.conf.Name, "dropped")
+			for i := 0; i < webhook.conf.QueueSize+5; i++ {
+				webhook.Emit(Event{Type: DrainStarted})
+			}
+			So(webhookEvents.Value(webhook.conf.Name, "dropped"), ShouldEqual, dropped+5)
+		})
+	})
+}
+
+func TestWebhookSet(t *testing.T) {
+
+	Convey("When the webhooks of a WebhookSet are reloaded", t, func() {
+		receiver := &receiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		webhookSet, err := NewWebhookSet([]WebhookConf{{URL: server.URL, Events: []string{DrainFailed}}})
+		So(err, ShouldBeNil)
+		webhookSet.Emit(Event{Type: DrainFailed, InstanceID: "i-34719eb8"})
+
+		Convey("the new webhooks should replace the previous ones, which send their queued events", func() {
+			So(webhookSet.Reload([]WebhookConf{{URL: server.URL, Events: []string{DeadlineAlert}}}), ShouldBeNil)
+			So(receiver.bodies, ShouldHaveLength, 1)
+
+			webhookSet.Emit(Event{Type: DrainFailed, InstanceID: "i-34719eb8"})
+			webhookSet.Emit(Event{Type: DeadlineAlert, InstanceID: "i-34719eb8"})
+			webhookSet.Stop()
+			So(receiver.bodies, ShouldHaveLength, 2)
+			So(receiver.bodies[1], ShouldContainSubstring, DeadlineAlert)
+		})
+		Convey("an invalid configuration should keep the current webhooks", func() {
+			So(webhookSet.Reload([]WebhookConf{{URL: "not an url"}}), ShouldNotBeNil)
+
+			webhookSet.Emit(Event{Type: DrainFailed, InstanceID: "i-34719eb8"})
+			webhookSet.Stop()
+			So(receiver.bodies, ShouldHaveLength, 2)
+		})
+	})
+}
+
+func TestWebhookConf(t *testing.T) {
+
+	Convey("Webhook configurations should be validated", t, func() {
+		So((&WebhookConf{URL: "not an url"}).Validate(), ShouldNotBeNil)
+		So((&WebhookConf{URL: "http://localhost", Body: "{{.Unclosed"}).Validate(), ShouldNotBeNil)
+		So((&WebhookConf{URL: "http://localhost"}).Validate(), ShouldBeNil)
+	})
+}
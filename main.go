@@ -2,27 +2,51 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/alanbover/deathnode/api"
 	"github.com/alanbover/deathnode/aurora"
 	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
 	"github.com/alanbover/deathnode/deathnode"
+	"github.com/alanbover/deathnode/events"
 	"github.com/alanbover/deathnode/mesos"
 	"github.com/benbjohnson/clock"
 
 	log "github.com/sirupsen/logrus"
 )
 
-var accessKey, secretKey, region, iamRole, iamSession, mesosURL string
+var accessKey, secretKey, region, iamRole, iamSession, mesosURL, configFile, listenAddress, auditLogPath string
+var apiToken, apiURL, lifecycleQueueURL string
 var debug bool
-var pollingSeconds int
+var pollingSeconds, runTimeoutSeconds, configReloadSeconds, livenessIntervals int
+var awsMaxRetries, awsRetryBaseDelayMs, awsRetryMaxDelayMs, awsRateBurst int
+var awsRateLimit float64
+var flagSources map[string]string
+
+// lifecycleQueueRetryDelay is the time to wait before receiving again from the lifecycle queue after an error
+const lifecycleQueueRetryDelay = 10 * time.Second
 
 func main() {
 
 	ctx := &context.ApplicationContext{Clock: clock.New()}
 
 	initFlags(ctx)
+	if flag.NArg() > 0 {
+		runCommand(flag.Args())
+		return
+	}
+
+	baseConf := ctx.Conf
+	if configFile != "" {
+		if err := context.LoadConfigFile(configFile, &ctx.Conf); err != nil {
+			log.Fatal(err)
+		}
+	}
 	enforceFlags(ctx)
 
 	log.SetLevel(log.InfoLevel)
@@ -47,17 +71,224 @@ func main() {
 		AuroraURL: ctx.Conf.AuroraURL,
 	}
 
+	// Emit every decision to the configured event sinks
+	eventBus := events.NewBus()
+	if auditLogPath != "" {
+		auditLog, err := events.OpenAuditLog(auditLogPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		eventBus.Subscribe(auditLog)
+	}
+	webhooks, err := events.NewWebhookSet(ctx.Conf.Webhooks)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eventBus.Subscribe(webhooks)
+	ctx.Events = eventBus
+
+	// Retry and rate limit the AWS calls, and record calls, errors and latency of every API call
+	ctx.AwsConn = aws.NewInstrumentedClient(aws.NewRetryingClient(ctx.AwsConn, awsRetryConf(), ctx.Clock))
+	ctx.MesosConn = mesos.NewInstrumentedClient(ctx.MesosConn)
+	ctx.AuroraConn = aurora.NewInstrumentedClient(ctx.AuroraConn)
+
+	// On dry-run, wrap the connections so no mutating call reaches AWS, Mesos or Aurora
+	if ctx.Conf.DryRun {
+		log.Info("Running in dry-run mode. No changes will be applied")
+		ctx.AwsConn = aws.NewDryRunClient(ctx.AwsConn)
+		ctx.MesosConn = mesos.NewDryRunClient(ctx.MesosConn)
+		ctx.AuroraConn = aurora.NewDryRunClient(ctx.AuroraConn)
+	}
+
+	// Autoscaling groups on other regions or accounts get their own connection, created on first use
+	dryRun := ctx.Conf.DryRun
+	ctx.AwsConnFactory = func(target context.AWSTarget) (aws.ClientInterface, error) {
+		return newAwsTargetConn(target, dryRun, ctx.Clock)
+	}
+
 	// Create deathnoteWatcher
 	deathNodeWatcher := deathnode.NewWatcher(ctx)
 
-	ticker := time.NewTicker(time.Second * time.Duration(pollingSeconds))
-	for {
-		go deathNodeWatcher.Run()
-		<-ticker.C
+	// Stop gracefully on SIGTERM/SIGINT, letting the current iteration finish. Reload config on SIGHUP.
+	// Pause removals on SIGUSR1 and resume them on SIGUSR2
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			log.Infof("Received signal %s", sig)
+			switch sig {
+			case syscall.SIGHUP:
+				reloadConf(deathNodeWatcher, webhooks, baseConf)
+				continue
+			case syscall.SIGUSR1:
+				deathNodeWatcher.Pause("signal")
+				continue
+			case syscall.SIGUSR2:
+				deathNodeWatcher.Resume("signal")
+				continue
+			}
+			close(stop)
+			return
+		}
+	}()
+
+	if configFile != "" && configReloadSeconds > 0 {
+		go watchConfigFile(deathNodeWatcher, webhooks, baseConf, time.Second*time.Duration(configReloadSeconds))
+	}
+
+	if listenAddress != "" {
+		server := api.NewServer(deathNodeWatcher, time.Second*time.Duration(pollingSeconds*livenessIntervals))
+		if apiToken != "" {
+			server.EnableOperatorAPI(apiToken)
+		}
+		go func() {
+			log.Fatal(server.ListenAndServe(listenAddress))
+		}()
+	}
+
+	// Destroy instances as soon as they reach Terminating:Wait. Polling still reconciles everything else
+	if lifecycleQueueURL != "" {
+		sqsQueue, err := aws.NewSQSQueue(accessKey, secretKey, region, iamRole, iamSession, lifecycleQueueURL)
+		if err != nil {
+			log.Fatal("Error connecting to SQS: ", err)
+		}
+		var queue aws.QueueInterface = sqsQueue
+		if ctx.Conf.DryRun {
+			queue = aws.NewDryRunQueue(queue)
+		}
+		go deathnode.NewLifecycleListener(queue, deathNodeWatcher, lifecycleQueueRetryDelay).Start(stop)
+	}
+
+	loop := deathnode.NewLoop(deathNodeWatcher,
+		time.Second*time.Duration(pollingSeconds), time.Second*time.Duration(runTimeoutSeconds))
+	loop.Start(stop)
+	webhooks.Stop()
+	log.Info("Deathnode stopped")
+}
+
+// runCommand calls the operator API of a running deathnode:
+// deathnode [flags] (mark|unmark|force|quarantine|unquarantine) <instanceId>
+// or deathnode [flags] (pause|resume)
+func runCommand(args []string) {
+
+	isInstanceCommand := len(args) == 2 &&
+		(args[0] == api.ActionMark || args[0] == api.ActionUnmark || args[0] == api.ActionForce ||
+			args[0] == api.ActionQuarantine || args[0] == api.ActionUnquarantine)
+	isPauseCommand := len(args) == 1 && (args[0] == api.ActionPause || args[0] == api.ActionResume)
+	if !isInstanceCommand && !isPauseCommand {
+		flag.Usage()
+		log.Fatal("Expected a command (mark, unmark, force, quarantine or unquarantine) and an instance id, or pause or resume")
+	}
+
+	if apiToken == "" {
+		log.Fatalf("apiToken flag (or %s env) is required", context.EnvName("apiToken"))
+	}
+
+	client := api.NewClient(apiURL, apiToken)
+	if isPauseCommand {
+		if err := client.SetPaused(args[0] == api.ActionPause); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("%s done", args[0])
+		return
+	}
+
+	if err := client.Do(args[0], args[1]); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%s %s done", args[0], args[1])
+}
+
+// newAwsTargetConn returns the connection to an AWS target, wrapped as the default one. Target fields left
+// empty fall back to the region and IAM role flags
+func newAwsTargetConn(target context.AWSTarget, dryRun bool, clk clock.Clock) (aws.ClientInterface, error) {
+
+	targetRegion, targetRole := region, iamRole
+	if target.Region != "" {
+		targetRegion = target.Region
+	}
+	if target.RoleARN != "" {
+		targetRole = target.RoleARN
+	}
+
+	log.Infof("Connecting to AWS target %s", target)
+	client, err := aws.NewClientWithExternalID(accessKey, secretKey, targetRegion, targetRole, iamSession,
+		target.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	var awsConn aws.ClientInterface = aws.NewInstrumentedClient(aws.NewRetryingClient(client, awsRetryConf(), clk))
+	if dryRun {
+		awsConn = aws.NewDryRunClient(awsConn)
+	}
+	return awsConn, nil
+}
+
+// awsRetryConf returns the retry and rate limit settings for every AWS connection
+func awsRetryConf() aws.RetryConf {
+
+	return aws.RetryConf{
+		MaxRetries: awsMaxRetries,
+		BaseDelay:  time.Millisecond * time.Duration(awsRetryBaseDelayMs),
+		MaxDelay:   time.Millisecond * time.Duration(awsRetryMaxDelayMs),
+		RateLimit:  awsRateLimit,
+		Burst:      awsRateBurst,
+	}
+}
+
+// reloadConf re-reads the config file on top of the flags configuration, and applies it to the watcher
+// and the webhooks only if it's valid
+func reloadConf(deathNodeWatcher *deathnode.Watcher, webhooks *events.WebhookSet, baseConf context.ApplicationConf) {
+
+	if configFile == "" {
+		log.Warn("No configFile provided. Nothing to reload")
+		return
+	}
+
+	conf := baseConf
+	if err := context.LoadConfigFile(configFile, &conf); err != nil {
+		log.Errorf("Unable to reload configuration, keeping the current one: %s", err)
+		return
+	}
+
+	if err := deathNodeWatcher.Reload(conf); err != nil {
+		log.Errorf("Invalid configuration, keeping the current one: %s", err)
+		return
+	}
+
+	if err := webhooks.Reload(conf.Webhooks); err != nil {
+		log.Errorf("Unable to reload webhooks, keeping the current ones: %s", err)
+	}
+
+	log.Infof("Configuration reloaded from %s", configFile)
+}
+
+// watchConfigFile reloads the configuration every time the config file modification time changes
+func watchConfigFile(deathNodeWatcher *deathnode.Watcher, webhooks *events.WebhookSet, baseConf context.ApplicationConf,
+	interval time.Duration) {
+
+	lastModTime := time.Time{}
+	if info, err := os.Stat(configFile); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(configFile)
+		if err != nil {
+			log.Warnf("Unable to check config file %s: %s", configFile, err)
+			continue
+		}
+
+		if !info.ModTime().Equal(lastModTime) {
+			lastModTime = info.ModTime()
+			reloadConf(deathNodeWatcher, webhooks, baseConf)
+		}
 	}
 }
 
-func initFlags(context *context.ApplicationContext) {
+func initFlags(ctx *context.ApplicationContext) {
 
 	flag.StringVar(&accessKey, "accessKey", "", "AWS_ACCESS_KEY_ID.")
 	flag.StringVar(&secretKey, "secretKey", "", "AWS_SECRET_ACCESS_KEY.")
@@ -65,49 +296,102 @@ func initFlags(context *context.ApplicationContext) {
 	flag.StringVar(&iamRole, "iamRole", "", "IAMROLE to assume.")
 	flag.StringVar(&iamSession, "iamSession", "", "Session for IAMROLE.")
 
+	flag.IntVar(&awsMaxRetries, "awsMaxRetries", 5, "Retries of the AWS calls failed by throttling, server or connection errors.")
+	flag.IntVar(&awsRetryBaseDelayMs, "awsRetryBaseDelay", 200, "Milliseconds of the first AWS retry backoff, doubled on every retry and randomized.")
+	flag.IntVar(&awsRetryMaxDelayMs, "awsRetryMaxDelay", 20000, "Maximum milliseconds between AWS retries.")
+	flag.Float64Var(&awsRateLimit, "awsRateLimit", 10, "Maximum AWS calls per second, per region and account (0 disables it).")
+	flag.IntVar(&awsRateBurst, "awsRateBurst", 20, "AWS calls allowed above awsRateLimit after an idle period.")
+
 	flag.BoolVar(&debug, "debug", false, "Enable debug logging.")
+	flag.StringVar(&configFile, "configFile", "", "JSON file with per autoscaling group settings. Reloaded on SIGHUP.")
+	flag.IntVar(&configReloadSeconds, "configReload", 0, "Seconds between checks for configFile changes (0 disables it).")
+	flag.StringVar(&listenAddress, "listen", "", "Address for the HTTP status API, metrics and health checks, i.e: :8080 (disabled if empty).")
+	flag.StringVar(&apiToken, "apiToken", "", "Bearer token for the operator API (disabled if empty). Also used by the commands.")
+	flag.StringVar(&apiURL, "apiUrl", "http://localhost:8080", "URL of the deathnode called by the mark, unmark, force, quarantine, unquarantine, pause and resume commands.")
+	flag.StringVar(&lifecycleQueueURL, "lifecycleQueueUrl", "", "SQS queue URL receiving the termination lifecycle notifications, on the region flag (disabled if empty).")
+	flag.StringVar(&auditLogPath, "auditLog", "", "File to append a JSON line per removal decision to, or - for stdout (disabled if empty).")
 	flag.StringVar(&mesosURL, "mesosUrl", "", "The URL for Mesos master.")
-	flag.StringVar(&context.Conf.AuroraURL, "auroraUrl", "", "The URL to the Aurora json API (apibeta)")
+	flag.StringVar(&ctx.Conf.AuroraURL, "auroraUrl", "", "The URL to the Aurora json API (apibeta)")
 
-	flag.Var(&context.Conf.AutoscalingGroupPrefixes, "autoscalingGroupName", "An autoscalingGroup prefix for monitor.")
-	flag.Var(&context.Conf.ProtectedFrameworks, "protectedFrameworks", "The mesos frameworks to wait for kill the node.")
-	flag.Var(&context.Conf.ProtectedTasksLabels, "protectedTaskLabels", "The labels used for protected tasks.")
+	flag.Var(&ctx.Conf.AutoscalingGroupPrefixes, "autoscalingGroupName", "An autoscalingGroup prefix for monitor.")
+	flag.Var(&ctx.Conf.ProtectedFrameworks, "protectedFrameworks", "The mesos frameworks to wait for kill the node.")
+	flag.Var(&ctx.Conf.ProtectedTasksLabels, "protectedTaskLabels", "The labels used for protected tasks.")
 
 	flag.Var(
-		&context.Conf.ConstraintsType, "constraintsType", "The constrainst implementation to use.")
+		&ctx.Conf.ConstraintsType, "constraintsType", "The constrainst implementation to use.")
 	flag.StringVar(
-		&context.Conf.RecommenderType, "recommenderType", "firstAvailableAgent", "The recommender implementation to use.")
+		&ctx.Conf.RecommenderType, "recommenderType", "firstAvailableAgent", "The recommender implementation to use.")
 	flag.StringVar(
-		&context.Conf.DeathNodeMark, "deathNodeMark", "DEATH_NODE_MARK", "The tag to apply for instances to be deleted.")
-	flag.BoolVar(&context.Conf.ResetLifecycle, "resetLifecycle", false, "Reset lifecycle when it's close to expire.")
+		&ctx.Conf.DeathNodeMark, "deathNodeMark", "DEATH_NODE_MARK", "The tag to apply for instances to be deleted.")
+	flag.BoolVar(&ctx.Conf.ResetLifecycle, "resetLifecycle", false, "Reset lifecycle when it's close to expire.")
 
 	flag.IntVar(&pollingSeconds, "polling", 60, "Seconds between executions.")
-	flag.IntVar(&context.Conf.LifecycleTimeout, "lifecycleTimeout", 3600, "the Terminating:Wait lifecycle timeout period.")
-	flag.BoolVar(&context.Conf.ForceLifeCycleHook, "forceLifecycleHook", false, "force (overwrite) all lifecycle hooks (ensures they match desired timeouts)")
-	flag.IntVar(&context.Conf.DelayDeleteSeconds, "delayDelete", 0, "Time to wait between kill executions (in seconds).")
+	flag.IntVar(&livenessIntervals, "livenessIntervals", 3, "Polling intervals without a completed execution before /healthz fails (0 disables it).")
+	flag.IntVar(&runTimeoutSeconds, "runTimeout", 0, "Seconds an execution may take before skipping its remaining steps (0 disables it).")
+	flag.IntVar(&ctx.Conf.LifecycleTimeout, "lifecycleTimeout", 3600, "the Terminating:Wait lifecycle timeout period.")
+	flag.IntVar(&ctx.Conf.LaunchTimeout, "launchTimeout", 0, "the seconds to wait for the Mesos agent of a new instance before abandoning its launch. 0 disables the launch lifecycle hook.")
+	flag.StringVar(&ctx.Conf.LifecycleDefaultResult, "lifecycleDefaultResult", "CONTINUE", "the result applied by AWS when the Terminating:Wait lifecycle timeout expires (CONTINUE or ABANDON).")
+	flag.IntVar(&ctx.Conf.DrainTimeout, "drainTimeout", 0, "Seconds after being marked before the drain of an instance still running protected tasks is declared failed (0 disables it).")
+	flag.StringVar(&ctx.Conf.DrainFailurePolicy, "drainFailurePolicy", "heartbeat", "what to do with the instances whose drain failed: heartbeat (keep waiting for its tasks) abandon (complete the lifecycle action with ABANDON) or standby (keep it running in Standby, only removing its scale-in protection once drained).")
+	flag.IntVar(&ctx.Conf.EscalationAlert, "escalationAlert", 3600, "Seconds before the AWS lifecycle action global timeout to alert about an instance still in Terminating:Wait (0 disables it).")
+	flag.IntVar(&ctx.Conf.EscalationForceDrain, "escalationForceDrain", 0, "Seconds before the AWS lifecycle action global timeout to destroy an instance without waiting for its tasks (0 disables it).")
+	flag.IntVar(&ctx.Conf.EscalationAbandon, "escalationAbandon", 0, "Seconds before the AWS lifecycle action global timeout to complete the lifecycle action of an instance with ABANDON (0 disables it).")
+	flag.StringVar(&ctx.Conf.UnhealthyPolicy, "unhealthyPolicy", "drain", "How to destroy the unhealthy instances terminated outside deathnode: drain (for up to unhealthyDrainTimeout seconds) or complete (without waiting for their tasks).")
+	flag.StringVar(&ctx.Conf.RemovalMode, "removalMode", "lifecycle", "How to remove the drained instances: lifecycle (complete the lifecycle action of the deathnode lifecycle hook) or detach (detach the instance from its autoscaling group and terminate it).")
+	flag.IntVar(&ctx.Conf.UnhealthyDrainTimeout, "unhealthyDrainTimeout", 300, "Seconds to drain the unhealthy instances terminated outside deathnode before destroying them.")
+	flag.BoolVar(&ctx.Conf.ForceLifeCycleHook, "forceLifecycleHook", false, "force (overwrite) all lifecycle hooks (ensures they match desired timeouts)")
+	flag.IntVar(&ctx.Conf.DelayDeleteSeconds, "delayDelete", 0, "Time to wait between kill executions (in seconds).")
+	flag.IntVar(&ctx.Conf.InstanceRefreshSeconds, "instanceRefresh", 600, "Seconds between refreshes of the IP and tags of the known instances (0 disables it).")
+	flag.BoolVar(&ctx.Conf.DryRun, "dryRun", false, "Compute every decision but only log the changes instead of applying them.")
 
+	flag.Usage = usage
 	flag.Parse()
+
+	sources, err := context.ApplyEnv(flag.CommandLine, os.LookupEnv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	flagSources = sources
 }
 
-func enforceFlags(context *context.ApplicationContext) {
+func usage() {
 
-	if mesosURL == "" {
-		flag.Usage()
-		log.Fatal("mesosUrl flag is required")
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s [flags]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s [flags] (mark|unmark|force|quarantine|unquarantine) <instanceId>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s [flags] (pause|resume)\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nEvery flag can also be set with the %s<FLAG_NAME> environment variable (i.e. %s), "+
+		"with flag > env > default precedence. Repeatable flags take a list of values separated by '%s'.\n",
+		context.EnvPrefix, context.EnvName("autoscalingGroupName"), context.EnvListSeparator)
+}
+
+// flagSource returns where the value of a flag came from, for logging purposes
+func flagSource(name string) string {
+
+	switch flagSources[name] {
+	case context.SourceFlag:
+		return "-" + name + " flag"
+	case context.SourceEnv:
+		return context.EnvName(name) + " env"
+	default:
+		return "default"
 	}
+}
+
+func enforceFlags(ctx *context.ApplicationContext) {
 
-	if len(context.Conf.AutoscalingGroupPrefixes) < 1 {
+	if mesosURL == "" {
 		flag.Usage()
-		log.Fatal("at least one autoscalingGroupName flag is required")
+		log.Fatalf("mesosUrl flag (or %s env) is required", context.EnvName("mesosUrl"))
 	}
 
-	if len(context.Conf.ProtectedFrameworks) < 1 {
+	if err := ctx.Conf.Validate(); err != nil {
 		flag.Usage()
-		log.Fatal("at least one protectedFrameworks flag is required")
+		log.Fatal(err)
 	}
 
-	if len(context.Conf.ConstraintsType) < 1 {
-		flag.Usage()
-		log.Fatal("at least one constraintsType flag is required")
+	for _, name := range []string{"mesosUrl", "autoscalingGroupName", "protectedFrameworks", "constraintsType"} {
+		log.Infof("Using %s from %s", name, flagSource(name))
 	}
 }
@@ -37,6 +37,7 @@ type Slave struct {
 	ID       string `json:"id"`
 	Pid      string `json:"pid"`
 	Hostname string `json:"hostname"`
+	Active   bool   `json:"active"`
 }
 
 // FrameworksResponse is part of the mesos frameworks response API endpoint
@@ -0,0 +1,43 @@
+package mesos
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// DryRunClient wraps a mesos client, forwarding the read-only calls and logging the mutating ones
+// instead of executing them
+type DryRunClient struct {
+	client ClientInterface
+}
+
+// NewDryRunClient returns a DryRunClient wrapping a mesos client
+func NewDryRunClient(client ClientInterface) *DryRunClient {
+	return &DryRunClient{client: client}
+}
+
+// GetMesosTasks return the running tasks on the Mesos cluster
+func (c *DryRunClient) GetMesosTasks() (*TasksResponse, error) {
+	return c.client.GetMesosTasks()
+}
+
+// GetMesosFrameworks returns the registered frameworks in Mesos
+func (c *DryRunClient) GetMesosFrameworks() (*FrameworksResponse, error) {
+	return c.client.GetMesosFrameworks()
+}
+
+// GetMesosAgents returns the Mesos Agents registered in the Mesos cluster
+func (c *DryRunClient) GetMesosAgents() (*SlavesResponse, error) {
+	return c.client.GetMesosAgents()
+}
+
+// UpdateMesosLeaderURL updates the URL to the currently leading Mesos Master
+func (c *DryRunClient) UpdateMesosLeaderURL() (string, error) {
+	return c.client.UpdateMesosLeaderURL()
+}
+
+// SetHostsInMaintenance logs the maintenance schedule without sending it to Mesos
+func (c *DryRunClient) SetHostsInMaintenance(hosts map[string]string) error {
+
+	log.WithField("hosts", hosts).Info("Dry-run: would set hosts in maintenance")
+	return nil
+}
@@ -0,0 +1,59 @@
+package mesos
+
+import (
+	"time"
+
+	"github.com/alanbover/deathnode/metrics"
+)
+
+const metricsClientName = "mesos"
+
+// InstrumentedClient wraps a mesos client, recording the number of calls, errors and latency of
+// every method
+type InstrumentedClient struct {
+	client ClientInterface
+}
+
+// NewInstrumentedClient returns an InstrumentedClient wrapping a mesos client
+func NewInstrumentedClient(client ClientInterface) *InstrumentedClient {
+	return &InstrumentedClient{client: client}
+}
+
+// GetMesosTasks returns the tasks running on the Mesos cluster
+func (c *InstrumentedClient) GetMesosTasks() (response *TasksResponse, err error) {
+
+	defer observe("GetMesosTasks", time.Now(), &err)
+	return c.client.GetMesosTasks()
+}
+
+// GetMesosFrameworks returns the frameworks registered on the Mesos cluster
+func (c *InstrumentedClient) GetMesosFrameworks() (response *FrameworksResponse, err error) {
+
+	defer observe("GetMesosFrameworks", time.Now(), &err)
+	return c.client.GetMesosFrameworks()
+}
+
+// GetMesosAgents returns the agents registered on the Mesos cluster
+func (c *InstrumentedClient) GetMesosAgents() (response *SlavesResponse, err error) {
+
+	defer observe("GetMesosAgents", time.Now(), &err)
+	return c.client.GetMesosAgents()
+}
+
+// UpdateMesosLeaderURL finds the current Mesos leader
+func (c *InstrumentedClient) UpdateMesosLeaderURL() (leaderURL string, err error) {
+
+	defer observe("UpdateMesosLeaderURL", time.Now(), &err)
+	return c.client.UpdateMesosLeaderURL()
+}
+
+// SetHostsInMaintenance sets the hosts in maintenance
+func (c *InstrumentedClient) SetHostsInMaintenance(hosts map[string]string) (err error) {
+
+	defer observe("SetHostsInMaintenance", time.Now(), &err)
+	return c.client.SetHostsInMaintenance(hosts)
+}
+
+func observe(method string, start time.Time, err *error) {
+	metrics.ObserveClientRequest(metricsClientName, method, start, *err)
+}
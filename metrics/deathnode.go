@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"time"
+)
+
+// DefaultRegistry stores all the deathnode metrics, exported on /metrics
+var DefaultRegistry = NewRegistry()
+
+// RemovalBuckets are the histogram upper bounds, in seconds, for the time taken to remove an instance
+var RemovalBuckets = []float64{60, 300, 600, 1800, 3600, 7200, 14400, 28800, 86400, 172800}
+
+var (
+	// AutoscalingGroups is the number of monitored autoscaling groups
+	AutoscalingGroups = DefaultRegistry.NewGauge("deathnode_autoscaling_groups",
+		"Number of monitored autoscaling groups.")
+	// DesiredInstances is the desired capacity of every autoscaling group
+	DesiredInstances = DefaultRegistry.NewGauge("deathnode_autoscaling_group_desired_instances",
+		"Desired capacity of the autoscaling group.", "autoscaling_group")
+	// Instances is the number of instances of every autoscaling group
+	Instances = DefaultRegistry.NewGauge("deathnode_autoscaling_group_instances",
+		"Actual instances in the autoscaling group.", "autoscaling_group")
+	// InstancesMarked is the number of instances tagged to be removed
+	InstancesMarked = DefaultRegistry.NewGauge("deathnode_instances_marked",
+		"Instances tagged to be removed.", "autoscaling_group")
+	// InstancesDraining is the number of instances in Terminating:Wait not destroyed yet
+	InstancesDraining = DefaultRegistry.NewGauge("deathnode_instances_draining",
+		"Instances in Terminating:Wait waiting for their tasks to finish.", "autoscaling_group")
+	// Paused is 1 when the removals are paused, globally (empty autoscaling_group) or on an autoscaling group
+	Paused = DefaultRegistry.NewGauge("deathnode_paused",
+		"Whether removals are paused, globally (empty autoscaling_group) or on the autoscaling group.",
+		"autoscaling_group")
+	// InstancesAwaitingTerminatingWait is the number of marked instances not in Terminating:Wait yet
+	InstancesAwaitingTerminatingWait = DefaultRegistry.NewGauge("deathnode_instances_awaiting_terminating_wait",
+		"Instances tagged to be removed that AWS has not moved to Terminating:Wait yet.", "autoscaling_group")
+	// OrphanInstances is the number of instances tagged to be removed outside the monitored autoscaling groups
+	OrphanInstances = DefaultRegistry.NewGauge("deathnode_orphan_instances",
+		"Instances tagged to be removed that don't belong to any monitored autoscaling group.")
+
+	// LifecycleActionsCompleted counts the lifecycle actions completed
+	LifecycleActionsCompleted = DefaultRegistry.NewCounter("deathnode_lifecycle_actions_completed_total",
+		"Lifecycle actions completed.", "autoscaling_group")
+	// LifecycleHeartbeats counts the lifecycle action heartbeats recorded
+	LifecycleHeartbeats = DefaultRegistry.NewCounter("deathnode_lifecycle_heartbeats_total",
+		"Lifecycle action heartbeats recorded.", "autoscaling_group")
+	// TagsSet counts the instances marked to be removed. The removal tag rewrites on heartbeats are not counted
+	TagsSet = DefaultRegistry.NewCounter("deathnode_tags_set_total",
+		"Instances marked to be removed.", "autoscaling_group")
+
+	// ClientRequests counts the calls to every AWS, Mesos and Aurora client method
+	ClientRequests = DefaultRegistry.NewCounter("deathnode_client_requests_total",
+		"Calls to the AWS, Mesos and Aurora APIs.", "client", "method")
+	// ClientErrors counts the failed calls to every AWS, Mesos and Aurora client method
+	ClientErrors = DefaultRegistry.NewCounter("deathnode_client_errors_total",
+		"Failed calls to the AWS, Mesos and Aurora APIs.", "client", "method")
+	// ClientThrottles counts the calls to every AWS client method throttled by AWS, including the retried ones
+	ClientThrottles = DefaultRegistry.NewCounter("deathnode_client_throttles_total",
+		"Calls to the AWS API throttled, including the ones retried. Not counted as errors.", "client", "method")
+	// ClientRetries counts the retries of every AWS client method
+	ClientRetries = DefaultRegistry.NewCounter("deathnode_client_retries_total",
+		"Calls to the AWS API retried after a throttling, server or connection error.", "client", "method")
+	// ClientRequestDuration observes the latency of every AWS, Mesos and Aurora client method
+	ClientRequestDuration = DefaultRegistry.NewHistogram("deathnode_client_request_duration_seconds",
+		"Latency of the calls to the AWS, Mesos and Aurora APIs.", DefaultBuckets, "client", "method")
+
+	// InstanceRemovalDuration observes the time from tagging an instance to completing its lifecycle action
+	InstanceRemovalDuration = DefaultRegistry.NewHistogram("deathnode_instance_removal_duration_seconds",
+		"Time from tagging an instance to be removed to completing its lifecycle action.", RemovalBuckets,
+		"autoscaling_group")
+)
+
+// ObserveClientRequest records a call to a client method started at start, failed if err is not nil
+func ObserveClientRequest(client, method string, start time.Time, err error) {
+
+	ClientRequests.Inc(client, method)
+	if err != nil {
+		ClientErrors.Inc(client, method)
+	}
+	ClientRequestDuration.Observe(time.Since(start).Seconds(), client, method)
+}
@@ -0,0 +1,292 @@
+package metrics
+
+// Minimal implementation of Prometheus counters, gauges and histograms, exported using the text
+// exposition format
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram upper bounds, in seconds, used for API call latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry stores a list of metrics and writes them in the Prometheus text format
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// series stores the values of a metric for every combination of label values
+type series struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	mutex      sync.Mutex
+	values     map[string][]string
+}
+
+// Counter is a metric that only goes up
+type Counter struct {
+	*series
+	counts map[string]float64
+}
+
+// Gauge is a metric that can be set to any value
+type Gauge struct {
+	*series
+	gauges map[string]float64
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	*series
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter registers a Counter with the given label names
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+
+	counter := &Counter{
+		series: newSeries(name, help, "counter", labelNames),
+		counts: map[string]float64{},
+	}
+	r.register(counter)
+	return counter
+}
+
+// NewGauge registers a Gauge with the given label names
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+
+	gauge := &Gauge{
+		series: newSeries(name, help, "gauge", labelNames),
+		gauges: map[string]float64{},
+	}
+	r.register(gauge)
+	return gauge
+}
+
+// NewHistogram registers a Histogram with the given bucket upper bounds and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+
+	histogram := &Histogram{
+		series:  newSeries(name, help, "histogram", labelNames),
+		buckets: buckets,
+		counts:  map[string][]uint64{},
+		sums:    map[string]float64{},
+	}
+	r.register(histogram)
+	return histogram
+}
+
+// Write writes all the registered metrics in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, metric := range r.metrics {
+		metric.write(w)
+	}
+}
+
+func (r *Registry) register(metric metric) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.metrics = append(r.metrics, metric)
+}
+
+func newSeries(name, help, metricType string, labelNames []string) *series {
+
+	return &series{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		values:     map[string][]string{},
+	}
+}
+
+// key returns the identifier of a combination of label values, storing it. Must be called holding the lock
+func (s *series) key(labelValues []string) string {
+
+	if len(labelValues) != len(s.labelNames) {
+		panic(fmt.Sprintf("Metric %s expects %d label values, got %d", s.name, len(s.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := s.values[key]; !ok {
+		s.values[key] = append([]string{}, labelValues...)
+	}
+	return key
+}
+
+// sortedKeys returns the stored label values identifiers sorted. Must be called holding the lock
+func (s *series) sortedKeys() []string {
+
+	keys := []string{}
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *series) writeHeader(w io.Writer) {
+
+	fmt.Fprintf(w, "# HELP %s %s\n", s.name, s.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", s.name, s.metricType)
+}
+
+// labels formats the label values for key, followed by the extra label pairs
+func (s *series) labels(key string, extra ...string) string {
+
+	pairs := []string{}
+	for i, value := range s.values[key] {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", s.labelNames[i], escapeLabelValue(value)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extra[i], escapeLabelValue(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Inc increments the counter by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter by value
+func (c *Counter) Add(value float64, labelValues ...string) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.counts[c.key(labelValues)] += value
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value(labelValues ...string) float64 {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.counts[strings.Join(labelValues, "\xff")]
+}
+
+func (c *Counter) write(w io.Writer) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(key), formatFloat(c.counts[key]))
+	}
+}
+
+// Set sets the gauge to value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.gauges[g.key(labelValues)] = value
+}
+
+// Reset removes all the label values of the gauge, so the ones not set again are no longer exported
+func (g *Gauge) Reset() {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.values = map[string][]string{}
+	g.gauges = map[string]float64{}
+}
+
+func (g *Gauge) write(w io.Writer) {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.writeHeader(w)
+	for _, key := range g.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labels(key), formatFloat(g.gauges[key]))
+	}
+}
+
+// Observe adds a value to the histogram
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := h.key(labelValues)
+	if _, ok := h.counts[key]; !ok {
+		// One counter per bucket, plus +Inf
+		h.counts[key] = make([]uint64, len(h.buckets)+1)
+	}
+
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			h.counts[key][i]++
+		}
+	}
+	h.counts[key][len(h.buckets)]++
+	h.sums[key] += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range h.sortedKeys() {
+		counts := h.counts[key]
+		for i, upperBound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", formatFloat(upperBound)), counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(key, "le", "+Inf"), counts[len(h.buckets)])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(key), formatFloat(h.sums[key]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(key), counts[len(h.buckets)])
+	}
+}
+
+func formatFloat(value float64) string {
+
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
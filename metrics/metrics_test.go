@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+
+	Convey("When writing the metrics of a registry", t, func() {
+		registry := NewRegistry()
+		counter := registry.NewCounter("test_total", "A counter.", "method")
+		gauge := registry.NewGauge("test_gauge", "A gauge.")
+		histogram := registry.NewHistogram("test_seconds", "A histogram.", []float64{1, 5}, "method")
+
+		counter.Inc("b")
+		counter.Add(2, "a\"quoted\"")
+		gauge.Set(3.5)
+		histogram.Observe(0.5, "a")
+		histogram.Observe(3, "a")
+		histogram.Observe(10, "a")
+
+		var buffer bytes.Buffer
+		registry.Write(&buffer)
+
+		Convey("it should follow the Prometheus text exposition format", func() {
+			So(buffer.String(), ShouldEqual, `# HELP test_total A counter.
+# TYPE test_total counter
+test_total{method="a\"quoted\""} 2
+test_total{method="b"} 1
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge 3.5
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="a",le="1"} 1
+test_seconds_bucket{method="a",le="5"} 2
+test_seconds_bucket{method="a",le="+Inf"} 3
+test_seconds_sum{method="a"} 13.5
+test_seconds_count{method="a"} 3
+`)
+		})
+		Convey("counters should return their value", func() {
+			So(counter.Value("b"), ShouldEqual, 1)
+			So(counter.Value("c"), ShouldEqual, 0)
+		})
+		Convey("resetting a gauge should remove its values", func() {
+			gauge.Reset()
+			buffer.Reset()
+			registry.Write(&buffer)
+			So(buffer.String(), ShouldNotContainSubstring, "test_gauge 3.5")
+		})
+	})
+}
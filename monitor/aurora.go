@@ -35,20 +35,25 @@ func NewAuroraMonitor(ctx *context.ApplicationContext) *AuroraMonitor {
 }
 
 // Refresh updates the aurora cache
-func (a *AuroraMonitor) Refresh() {
+func (a *AuroraMonitor) Refresh() error {
 
-	a.auroraCache.maintenance = a.getMaintenance()
+	maintenance, err := a.getMaintenance()
+	a.auroraCache.maintenance = maintenance
+	return err
 }
 
-func (a *AuroraMonitor) getMaintenance() aurora.MaintenanceResponse {
+func (a *AuroraMonitor) getMaintenance() (aurora.MaintenanceResponse, error) {
 
 	maintenanceResponse, err := a.ctx.AuroraConn.GetMaintenance()
 	if err != nil {
 		log.Warning(err)
-		return *maintenanceResponse
+		if maintenanceResponse == nil {
+			return aurora.MaintenanceResponse{}, err
+		}
+		return *maintenanceResponse, err
 	}
 
-	return *maintenanceResponse
+	return *maintenanceResponse, nil
 
 }
 
@@ -130,3 +135,17 @@ func (a *AuroraMonitor) isScheduled(host string) bool {
 
 	return false
 }
+
+// MaintenanceMode returns the Aurora maintenance mode for the host: DRAINED, DRAINING, SCHEDULED or NONE
+func (a *AuroraMonitor) MaintenanceMode(ipAddress string) string {
+
+	switch {
+	case a.IsDrained(ipAddress):
+		return "DRAINED"
+	case a.IsDraining(ipAddress):
+		return "DRAINING"
+	case a.isScheduled(ipAddress):
+		return "SCHEDULED"
+	}
+	return "NONE"
+}
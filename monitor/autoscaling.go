@@ -2,55 +2,89 @@ package monitor
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	log "github.com/sirupsen/logrus"
 )
 
-// AutoscalingServiceMonitor holds a map of [ASGprefix][ASGname]AutoscalingGroupMonitor
+// AutoscalingServiceMonitor holds a map of [selectorID][ASGname]AutoscalingGroupMonitor
 type AutoscalingServiceMonitor struct {
 	autoscalingMonitors map[string]map[string]*AutoscalingGroupMonitor
+	selectors           []*context.AutoscalingGroupConf
+	lastInstanceRefresh time.Time
 	ctx                 *context.ApplicationContext
 }
 
+// autoscalingGroupRefresh pairs an AutoscalingGroupMonitor with its autoscaling group on the current refresh
+type autoscalingGroupRefresh struct {
+	monitor          *AutoscalingGroupMonitor
+	autoscalingGroup *autoscaling.Group
+}
+
 // AutoscalingGroupMonitor monitors an AWS autoscaling group, caching it's data
 type AutoscalingGroupMonitor struct {
 	autoscalingGroupName string
 	desiredCapacity      int64
+	paused               bool
+	tags                 map[string]string
 	instanceMonitors     map[string]*InstanceMonitor
+	cancelledRemovals    []*InstanceMonitor
+	staleLaunchHook      bool
+	conf                 *context.AutoscalingGroupConf
+	awsConn              aws.ClientInterface
 	ctx                  *context.ApplicationContext
 }
 
 const (
+	// PausedTag pauses the removals on an autoscaling group when set to "true" on it
+	PausedTag = "deathnode:paused"
 	// LifeCycleRefreshTimeoutPercentage sets the percentage of LifeCycleTimeout to wait before reset it
 	LifeCycleRefreshTimeoutPercentage = 0.75
+	// LaunchHookTimeoutMultiplier sets the heartbeat timeout of the launch lifecycle hook as a multiple of
+	// LaunchTimeout, so deathnode abandons the launches before AWS does
+	LaunchHookTimeoutMultiplier = 2
 )
 
 // NewAutoscalingServiceMonitor returns an AutoscalingServiceMonitor object
 func NewAutoscalingServiceMonitor(ctx *context.ApplicationContext) *AutoscalingServiceMonitor {
 
+	selectors := ctx.Conf.Selectors()
 	autoscalingMonitors := map[string]map[string]*AutoscalingGroupMonitor{}
-	for _, autoscalingGroupPrefix := range ctx.Conf.AutoscalingGroupPrefixes {
-		autoscalingMonitors[autoscalingGroupPrefix] = map[string]*AutoscalingGroupMonitor{}
+	for _, selector := range selectors {
+		autoscalingMonitors[selector.ID()] = map[string]*AutoscalingGroupMonitor{}
 	}
 
 	autoscalingServiceMonitor := &AutoscalingServiceMonitor{
 		autoscalingMonitors: autoscalingMonitors,
+		selectors:           selectors,
 		ctx:                 ctx,
 	}
 
 	return autoscalingServiceMonitor
 }
 
-// NewAutoscalingGroupMonitor returns a "empty" AutoscalingGroupMonitor object
-func newAutoscalingGroupMonitor(ctx *context.ApplicationContext,
+// NewAutoscalingGroupMonitor returns a "empty" AutoscalingGroupMonitor object, using the AWS connection
+// of the selector target
+func newAutoscalingGroupMonitor(ctx *context.ApplicationContext, conf *context.AutoscalingGroupConf,
 	autoscalingGroupName string) (*AutoscalingGroupMonitor, error) {
 
+	awsConn, err := ctx.AwsConnFor(conf.Target())
+	if err != nil {
+		return nil, err
+	}
+
 	return &AutoscalingGroupMonitor{
 		autoscalingGroupName: autoscalingGroupName,
 		desiredCapacity:      0,
 		instanceMonitors:     map[string]*InstanceMonitor{},
+		conf:                 conf,
+		awsConn:              awsConn,
 		ctx:                  ctx,
 	}, nil
 }
@@ -58,8 +92,8 @@ func newAutoscalingGroupMonitor(ctx *context.ApplicationContext,
 // GetInstanceByID returns the instanceMonitor related with the instanceId
 func (a *AutoscalingServiceMonitor) GetInstanceByID(instanceID string) (*InstanceMonitor, error) {
 
-	for _, autoscalingPrefix := range a.autoscalingMonitors {
-		for _, autoscalingMonitor := range autoscalingPrefix {
+	for _, autoscalingSelector := range a.autoscalingMonitors {
+		for _, autoscalingMonitor := range autoscalingSelector {
 			if instance, ok := autoscalingMonitor.instanceMonitors[instanceID]; ok {
 				return instance, nil
 			}
@@ -68,20 +102,88 @@ func (a *AutoscalingServiceMonitor) GetInstanceByID(instanceID string) (*Instanc
 	return nil, fmt.Errorf("InstanceId %s not found", instanceID)
 }
 
+// GetAutoscalingGroupMonitorByInstanceID returns the AutoscalingGroupMonitor monitoring the instance. Unlike
+// GetAutoscalingGroupMonitor, it's not ambiguous when autoscaling groups in different targets share a name
+func (a *AutoscalingServiceMonitor) GetAutoscalingGroupMonitorByInstanceID(instanceID string) (*AutoscalingGroupMonitor, error) {
+
+	for _, autoscalingSelector := range a.autoscalingMonitors {
+		for _, autoscalingMonitor := range autoscalingSelector {
+			if _, ok := autoscalingMonitor.instanceMonitors[instanceID]; ok {
+				return autoscalingMonitor, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("No autoscaling group found for InstanceId %s", instanceID)
+}
+
+// GetAutoscalingGroupMonitor returns the AutoscalingGroupMonitor related with the autoscaling group name
+func (a *AutoscalingServiceMonitor) GetAutoscalingGroupMonitor(autoscalingGroupName string) (*AutoscalingGroupMonitor, error) {
+
+	for _, autoscalingSelector := range a.autoscalingMonitors {
+		if autoscalingMonitor, ok := autoscalingSelector[autoscalingGroupName]; ok {
+			return autoscalingMonitor, nil
+		}
+	}
+	return nil, fmt.Errorf("Autoscaling group %s not found", autoscalingGroupName)
+}
+
 // GetAutoscalingGroupMonitorsList returns all AutoscalingGroupMonitors cached in AutoscalingGroups in a list
 func (a *AutoscalingServiceMonitor) GetAutoscalingGroupMonitorsList() []*AutoscalingGroupMonitor {
 
 	var monitors = []*AutoscalingGroupMonitor{}
 
-	for autoscalingGroupPrefix := range a.autoscalingMonitors {
-		for autoscalingGroupName := range a.autoscalingMonitors[autoscalingGroupPrefix] {
-			monitors = append(monitors, a.autoscalingMonitors[autoscalingGroupPrefix][autoscalingGroupName])
+	for _, selector := range a.selectors {
+		for _, autoscalingGroupMonitor := range a.autoscalingMonitors[selector.ID()] {
+			monitors = append(monitors, autoscalingGroupMonitor)
 		}
 	}
 
 	return monitors
 }
 
+// Reload updates the selectors from the current configuration. AutoscalingGroupMonitors still matched
+// by any selector are kept, with their instances, under the first selector matching them, and their
+// lifecycle hooks are reconciled with the new settings
+func (a *AutoscalingServiceMonitor) Reload() {
+
+	selectors := a.ctx.Conf.Selectors()
+	autoscalingMonitors := map[string]map[string]*AutoscalingGroupMonitor{}
+	for _, selector := range selectors {
+		autoscalingMonitors[selector.ID()] = map[string]*AutoscalingGroupMonitor{}
+	}
+
+	for _, autoscalingGroupMonitor := range a.GetAutoscalingGroupMonitorsList() {
+		selector, ok := findSelector(autoscalingGroupMonitor.autoscalingGroupName, autoscalingGroupMonitor.tags,
+			selectors)
+		if ok && selector.Target() == autoscalingGroupMonitor.conf.Target() {
+			autoscalingGroupMonitor.conf = selector
+			if err := autoscalingGroupMonitor.reconcileLifecycleHooks(false); err != nil {
+				log.Warnf("Error putting lifecyclehook to autoscaling %s: %s",
+					autoscalingGroupMonitor.autoscalingGroupName, err)
+			}
+			autoscalingMonitors[selector.ID()][autoscalingGroupMonitor.autoscalingGroupName] = autoscalingGroupMonitor
+		} else {
+			log.Infof("Autoscaling group %s no longer selected. Stop monitoring it",
+				autoscalingGroupMonitor.autoscalingGroupName)
+		}
+	}
+
+	a.selectors = selectors
+	a.autoscalingMonitors = autoscalingMonitors
+}
+
+func findSelector(autoscalingGroupName string, tags map[string]string,
+	selectors []*context.AutoscalingGroupConf) (*context.AutoscalingGroupConf, bool) {
+
+	for _, selector := range selectors {
+		if selector.Matches(autoscalingGroupName, tags) {
+			return selector, true
+		}
+	}
+
+	return nil, false
+}
+
 func findAutoscalingGroup(autoscalingGroupName string,
 	response []*autoscaling.Group) (*autoscaling.Group, bool) {
 
@@ -94,71 +196,323 @@ func findAutoscalingGroup(autoscalingGroupName string,
 	return nil, false
 }
 
-// Refresh updates autoscalingGroups caching all AWS autoscaling groups given the N prefixes
-// provided when AutoscalingGroups was created
+// Refresh updates autoscalingGroups caching all AWS autoscaling groups given the N selectors
+// provided when AutoscalingGroups was created. An autoscaling group matched by more than one
+// selector is only monitored by the first of them. The new instances of all the autoscaling groups
+// are described together, and the known ones every InstanceRefreshSeconds. It returns the last error
+// found refreshing the selectors, if any
 func (a *AutoscalingServiceMonitor) Refresh() error {
 
-	for autoscalingGroupPrefix := range a.autoscalingMonitors {
-		if err := a.refreshAutoscalingPrefix(autoscalingGroupPrefix); err != nil {
+	var refreshErr error
+	claimed := map[string]bool{}
+	refreshes := []*autoscalingGroupRefresh{}
+	for _, selector := range a.selectors {
+		selectorRefreshes, err := a.refreshAutoscalingSelector(selector, claimed)
+		if err != nil {
 			log.Warning(err)
+			refreshErr = err
+			continue
 		}
+		refreshes = append(refreshes, selectorRefreshes...)
 	}
-	return nil
+
+	descriptions := a.describeNewInstances(refreshes)
+	for _, refresh := range refreshes {
+		refresh.monitor.refresh(refresh.autoscalingGroup, descriptions)
+	}
+
+	a.refreshKnownInstances()
+	return refreshErr
 }
 
-func (a *AutoscalingServiceMonitor) refreshAutoscalingPrefix(prefix string) error {
+func (a *AutoscalingServiceMonitor) refreshAutoscalingSelector(selector *context.AutoscalingGroupConf,
+	claimed map[string]bool) ([]*autoscalingGroupRefresh, error) {
 
-	response, err := a.ctx.AwsConn.DescribeAGsByPrefix(prefix)
+	monitors := a.autoscalingMonitors[selector.ID()]
+
+	response, err := a.describeAutoscalingGroups(selector)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Autoscaling groups names are only unique in a region and account
+	target := selector.Target()
+	autoscalingGroups := []*autoscaling.Group{}
+	for _, autoscalingGroup := range response {
+		name := *autoscalingGroup.AutoScalingGroupName
+		claimKey := target.String() + "/" + name
+		if selector.Matches(name, tagsMap(autoscalingGroup.Tags)) && !claimed[claimKey] {
+			autoscalingGroups = append(autoscalingGroups, autoscalingGroup)
+			claimed[claimKey] = true
+		}
 	}
-	if len(response) == 0 {
-		log.Warnf("No autoscaling groups found under autoscalingGroupPrefix %s",
-			prefix)
+	if len(autoscalingGroups) == 0 {
+		log.Warnf("No autoscaling groups found under autoscaling group selector %s",
+			selector.ID())
 	}
 
 	// find new autoscalingGroups
-	for _, autoscalingGroup := range response {
-		if _, ok := a.autoscalingMonitors[prefix][*autoscalingGroup.AutoScalingGroupName]; !ok {
-			a.newAutoscalingGroupMonitor(prefix, *autoscalingGroup.AutoScalingGroupName)
+	for _, autoscalingGroup := range autoscalingGroups {
+		if _, ok := monitors[*autoscalingGroup.AutoScalingGroupName]; !ok {
+			a.newAutoscalingGroupMonitor(selector, *autoscalingGroup.AutoScalingGroupName)
 		}
 	}
 
-	for autoscalingGroupName := range a.autoscalingMonitors[prefix] {
-		if autoscalingGroup, ok := findAutoscalingGroup(autoscalingGroupName, response); ok {
-			a.autoscalingMonitors[prefix][autoscalingGroupName].refresh(autoscalingGroup)
+	refreshes := []*autoscalingGroupRefresh{}
+	for autoscalingGroupName := range monitors {
+		if autoscalingGroup, ok := findAutoscalingGroup(autoscalingGroupName, autoscalingGroups); ok {
+			refreshes = append(refreshes, &autoscalingGroupRefresh{
+				monitor:          monitors[autoscalingGroupName],
+				autoscalingGroup: autoscalingGroup,
+			})
 		} else {
 			log.Infof("Autoscaling group %s removed. Deleting it", autoscalingGroupName)
-			delete(a.autoscalingMonitors[prefix], autoscalingGroupName)
+			delete(monitors, autoscalingGroupName)
 		}
 	}
 
-	return nil
+	return refreshes, nil
+}
+
+// describeNewInstances describes the instances not monitored yet of all the autoscaling groups being
+// refreshed, with one batch of calls per AWS connection
+func (a *AutoscalingServiceMonitor) describeNewInstances(refreshes []*autoscalingGroupRefresh) map[string]*ec2.Instance {
+
+	instanceIDs := instanceIDsByConn{}
+	for _, refresh := range refreshes {
+		for _, instance := range refresh.autoscalingGroup.Instances {
+			if _, ok := refresh.monitor.instanceMonitors[*instance.InstanceId]; !ok {
+				instanceIDs.add(refresh.monitor.awsConn, *instance.InstanceId)
+			}
+		}
+	}
+
+	return instanceIDs.describe()
+}
+
+// refreshKnownInstances updates the IP and tags of all the monitored instances from EC2, once every
+// InstanceRefreshSeconds. 0 disables it. The first refresh only starts counting, as all the instances
+// have just been described
+func (a *AutoscalingServiceMonitor) refreshKnownInstances() {
+
+	interval := time.Duration(a.ctx.Conf.InstanceRefreshSeconds) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	now := a.ctx.Clock.Now()
+	if a.lastInstanceRefresh.IsZero() {
+		a.lastInstanceRefresh = now
+		return
+	}
+	if now.Sub(a.lastInstanceRefresh) < interval {
+		return
+	}
+	a.lastInstanceRefresh = now
+
+	instanceIDs := instanceIDsByConn{}
+	instanceMonitors := map[string][]*InstanceMonitor{}
+	for _, autoscalingGroupMonitor := range a.GetAutoscalingGroupMonitorsList() {
+		for _, instanceMonitor := range autoscalingGroupMonitor.GetAllInstances() {
+			instanceIDs.add(instanceMonitor.awsConn, instanceMonitor.instanceID)
+			instanceMonitors[instanceMonitor.instanceID] = append(
+				instanceMonitors[instanceMonitor.instanceID], instanceMonitor)
+		}
+	}
+
+	log.Debugf("Refreshing the description of %d instances", len(instanceMonitors))
+	for instanceID, description := range instanceIDs.describe() {
+		for _, instanceMonitor := range instanceMonitors[instanceID] {
+			instanceMonitor.refreshDescription(description)
+		}
+	}
+}
+
+// instanceIDsByConn groups instance IDs by the AWS connection able to describe them, keeping the
+// order in which the connections were added
+type instanceIDsByConn struct {
+	awsConns    []aws.ClientInterface
+	instanceIDs map[aws.ClientInterface][]string
 }
 
-func (a *AutoscalingServiceMonitor) newAutoscalingGroupMonitor(autoscalingGroupPrefix string,
+func (n *instanceIDsByConn) add(awsConn aws.ClientInterface, instanceID string) {
+
+	if n.instanceIDs == nil {
+		n.instanceIDs = map[aws.ClientInterface][]string{}
+	}
+	if _, ok := n.instanceIDs[awsConn]; !ok {
+		n.awsConns = append(n.awsConns, awsConn)
+	}
+	n.instanceIDs[awsConn] = append(n.instanceIDs[awsConn], instanceID)
+}
+
+// describe returns the descriptions found for the instance IDs, by instance ID
+func (n *instanceIDsByConn) describe() map[string]*ec2.Instance {
+
+	descriptions := map[string]*ec2.Instance{}
+	for _, awsConn := range n.awsConns {
+		instances, err := awsConn.DescribeInstancesByIDs(n.instanceIDs[awsConn])
+		if err != nil {
+			log.Errorf("Unable to describe %d instances: %s", len(n.instanceIDs[awsConn]), err)
+			continue
+		}
+		for _, instance := range instances {
+			if instance.InstanceId != nil {
+				descriptions[*instance.InstanceId] = instance
+			}
+		}
+	}
+	return descriptions
+}
+
+// RefreshAutoscalingGroupMonitor updates a single autoscaling group, describing only it and its new instances
+func (a *AutoscalingServiceMonitor) RefreshAutoscalingGroupMonitor(autoscalingGroupMonitor *AutoscalingGroupMonitor) error {
+
+	autoscalingGroup, err := autoscalingGroupMonitor.awsConn.DescribeAGByName(autoscalingGroupMonitor.autoscalingGroupName)
+	if err != nil {
+		return err
+	}
+
+	refreshes := []*autoscalingGroupRefresh{{monitor: autoscalingGroupMonitor, autoscalingGroup: autoscalingGroup}}
+	return autoscalingGroupMonitor.refresh(autoscalingGroup, a.describeNewInstances(refreshes))
+}
+
+// describeAutoscalingGroups returns the autoscaling groups that may be matched by the selector: the ones
+// with its tags if it has any, or the ones with its prefix otherwise
+func (a *AutoscalingServiceMonitor) describeAutoscalingGroups(
+	selector *context.AutoscalingGroupConf) ([]*autoscaling.Group, error) {
+
+	awsConn, err := a.ctx.AwsConnFor(selector.Target())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to AWS for autoscaling group selector %s: %s", selector.ID(), err)
+	}
+
+	if len(selector.Tags) > 0 {
+		return awsConn.DescribeAGsByTags(selector.Tags)
+	}
+	return awsConn.DescribeAGsByPrefix(selector.Prefix)
+}
+
+func tagsMap(tagDescriptions []*autoscaling.TagDescription) map[string]string {
+
+	tags := map[string]string{}
+	for _, tag := range tagDescriptions {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	return tags
+}
+
+func (a *AutoscalingServiceMonitor) newAutoscalingGroupMonitor(selector *context.AutoscalingGroupConf,
 	autoscalingGroupName string) {
 
 	log.Infof("Found new autoscalingGroup to monitor: %s", autoscalingGroupName)
-	autoscalingGroupMonitor, _ := newAutoscalingGroupMonitor(a.ctx, autoscalingGroupName)
-
-	// Set life cycle hook if it's not set already
-	ok, _ := a.ctx.AwsConn.HasLifeCycleHook(autoscalingGroupName)
-	if !ok || a.ctx.Conf.ForceLifeCycleHook {
-		log.Infof("Setting lifecyclehook for autoscaling %s", autoscalingGroupName)
-		lifeCycleTimeout := int64(a.ctx.Conf.LifecycleTimeout)
-		err := a.ctx.AwsConn.PutLifeCycleHook(autoscalingGroupName, &lifeCycleTimeout)
+	autoscalingGroupMonitor, err := newAutoscalingGroupMonitor(a.ctx, selector, autoscalingGroupName)
+	if err != nil {
+		log.Warnf("Unable to monitor autoscaling %s: %s", autoscalingGroupName, err)
+		return
+	}
+
+	if err := autoscalingGroupMonitor.reconcileLifecycleHooks(a.ctx.Conf.ForceLifeCycleHook); err != nil {
+		log.Warnf("Error putting lifecyclehook to autoscaling %s: %s", autoscalingGroupName, err)
+		return
+	}
+
+	a.autoscalingMonitors[selector.ID()][autoscalingGroupName] = autoscalingGroupMonitor
+}
+
+// reconcileLifecycleHooks puts the deathnode lifecycle hooks missing on the autoscaling group, or whose
+// heartbeat timeout or default result drifted from its settings. With force, they are always put. Autoscaling
+// groups with the detach removal mode don't get any
+func (a *AutoscalingGroupMonitor) reconcileLifecycleHooks(force bool) error {
+
+	settings := a.Settings()
+	if settings.RemovalMode == context.RemovalDetach {
+		log.Debugf("Autoscaling %s removes instances detaching them. Not setting lifecyclehooks", a.autoscalingGroupName)
+		return a.findStaleLaunchHook()
+	}
+
+	lifecycleTimeout := int64(settings.LifecycleTimeout)
+	hook, _ := a.awsConn.DescribeLifeCycleHook(a.autoscalingGroupName, aws.LifecycleHookName)
+	if force || hookDrifted(hook, lifecycleTimeout, settings.LifecycleDefaultResult) {
+		log.Infof("Setting lifecyclehook for autoscaling %s", a.autoscalingGroupName)
+		err := a.awsConn.PutLifeCycleHook(a.autoscalingGroupName, &lifecycleTimeout, settings.LifecycleDefaultResult)
 		if err != nil {
-			log.Warnf("Error putting lifecyclehook to autoscaling %s: %s",
-				autoscalingGroupName, err)
-			return
+			return err
 		}
 	} else {
-		log.Infof("Autoscaling %s already have set lifecyclehook. Ignoring it...",
-			autoscalingGroupName)
+		log.Infof("Autoscaling %s already have set lifecyclehook. Ignoring it...", a.autoscalingGroupName)
+	}
+
+	if settings.LaunchTimeout <= 0 {
+		return a.findStaleLaunchHook()
+	}
+
+	// AWS only abandons the launch by itself if deathnode is not running
+	heartbeatTimeout := int64(settings.LaunchTimeout * LaunchHookTimeoutMultiplier)
+	hook, _ = a.awsConn.DescribeLifeCycleHook(a.autoscalingGroupName, aws.LaunchLifecycleHookName)
+	if force || hookDrifted(hook, heartbeatTimeout, aws.LifecycleActionAbandon) {
+		log.Infof("Setting launch lifecyclehook for autoscaling %s", a.autoscalingGroupName)
+		return a.awsConn.PutLaunchLifeCycleHook(a.autoscalingGroupName, &heartbeatTimeout)
 	}
+	return nil
+}
+
+// findStaleLaunchHook flags the launch lifecycle hook to be deleted on the next refresh if it exists while the
+// launch completion is disabled, so new instances aren't left in Pending:Wait to be abandoned
+func (a *AutoscalingGroupMonitor) findStaleLaunchHook() error {
 
-	a.autoscalingMonitors[autoscalingGroupPrefix][autoscalingGroupName] = autoscalingGroupMonitor
+	hook, err := a.awsConn.DescribeLifeCycleHook(a.autoscalingGroupName, aws.LaunchLifecycleHookName)
+	if err != nil {
+		return err
+	}
+	a.staleLaunchHook = hook != nil
+	return nil
+}
+
+// deleteStaleLaunchHook completes with CONTINUE the launch of the instances waiting in Pending:Wait, as AWS
+// abandons them when the hook is deleted, and then deletes the launch lifecycle hook
+func (a *AutoscalingGroupMonitor) deleteStaleLaunchHook() {
+
+	if !a.staleLaunchHook {
+		return
+	}
+
+	for _, instanceMonitor := range a.instanceMonitors {
+		if !instanceMonitor.isLaunchPending() {
+			continue
+		}
+		log.Infof("Launch completion disabled. Completing launch of instance %s", instanceMonitor.instanceID)
+		err := instanceMonitor.CompleteLaunch(aws.LifecycleActionContinue)
+		instanceMonitor.EmitEvent(events.LaunchCompleted, nil, err)
+		if err != nil {
+			log.Warnf("Unable to complete launch lifecycle action of instance %s: %s", instanceMonitor.instanceID, err)
+			return
+		}
+	}
+
+	log.Infof("Deleting launch lifecyclehook for autoscaling %s", a.autoscalingGroupName)
+	if err := a.awsConn.DeleteLaunchLifeCycleHook(a.autoscalingGroupName); err != nil {
+		log.Warnf("Unable to delete launch lifecyclehook for autoscaling %s: %s", a.autoscalingGroupName, err)
+		return
+	}
+	a.staleLaunchHook = false
+}
+
+// hookDrifted is true when the lifecycle hook doesn't exist, or has another heartbeat timeout or default result
+func hookDrifted(hook *autoscaling.LifecycleHook, heartbeatTimeout int64, defaultResult string) bool {
+
+	if hook == nil {
+		return true
+	}
+	if hook.HeartbeatTimeout == nil || *hook.HeartbeatTimeout != heartbeatTimeout ||
+		hook.DefaultResult == nil || *hook.DefaultResult != defaultResult {
+		log.Infof("Lifecyclehook %s of autoscaling %s drifted from the configuration", *hook.LifecycleHookName,
+			*hook.AutoScalingGroupName)
+		return true
+	}
+	return false
 }
 
 // GetAutoscalingGroupName returns the name of the autoscaling group
@@ -166,36 +520,105 @@ func (a *AutoscalingGroupMonitor) GetAutoscalingGroupName() string {
 	return a.autoscalingGroupName
 }
 
-// GetNumUndesiredInstances return the number of instances to be removed from the AutoscalingGroup
+// Conf returns the selector that matched the autoscaling group
+func (a *AutoscalingGroupMonitor) Conf() *context.AutoscalingGroupConf {
+	return a.conf
+}
+
+// Settings returns the effective settings for the autoscaling group
+func (a *AutoscalingGroupMonitor) Settings() context.AutoscalingGroupSettings {
+	return a.ctx.Conf.Settings(a.conf)
+}
+
+// GetNumUndesiredInstances return the number of instances to be removed from the AutoscalingGroup. Instances in
+// Standby or waiting for their launch to be completed are not part of its capacity, so they are not counted
 func (a *AutoscalingGroupMonitor) GetNumUndesiredInstances() int {
 
-	activeInstances := len(a.instanceMonitors) - len(a.getInstancesMarkedToBeRemoved())
+	instances, markedInstances := a.countCapacityInstances()
+	activeInstances := instances - markedInstances
 	if activeInstances > int(a.desiredCapacity) {
-		return len(a.instanceMonitors) - int(a.desiredCapacity)
+		return instances - int(a.desiredCapacity)
 	}
 
 	return 0
 }
 
+// IsAboveDesiredCapacity is true when the autoscaling group has more instances than its desired capacity,
+// not counting the ones already detached, in Standby or waiting for their launch to be completed
+func (a *AutoscalingGroupMonitor) IsAboveDesiredCapacity() bool {
+
+	instances, _ := a.countCapacityInstances()
+	return instances > int(a.desiredCapacity)
+}
+
+// countCapacityInstances returns the number of instances counted on the capacity of the autoscaling group, all
+// but the ones detached, in Standby or waiting for their launch to be completed, and how many of them are marked
+// to be removed
+func (a *AutoscalingGroupMonitor) countCapacityInstances() (int, int) {
+
+	instances, markedInstances := 0, 0
+	for _, instanceMonitor := range a.instanceMonitors {
+		if instanceMonitor.detached || instanceMonitor.lifecycleState == LifecycleStateStandby ||
+			instanceMonitor.isLaunchPending() {
+			continue
+		}
+		instances++
+		if instanceMonitor.IsMarkedToBeRemoved() {
+			markedInstances++
+		}
+	}
+	return instances, markedInstances
+}
+
 // GetInstances return the instances in AutoscalingGroupMonitor cache that
-// doesn't have the deathnode mark
+// doesn't have the deathnode mark. Instances in Standby are left out, as they can't be scaled in, and so are the
+// ones waiting for their launch to be completed, which have no Mesos agent yet
 func (a *AutoscalingGroupMonitor) GetInstances() []*InstanceMonitor {
-	return a.getInstances(false)
+
+	instances := []*InstanceMonitor{}
+	for _, instanceMonitor := range a.getInstances(false) {
+		if instanceMonitor.lifecycleState != LifecycleStateStandby && !instanceMonitor.isLaunchPending() {
+			instances = append(instances, instanceMonitor)
+		}
+	}
+	return instances
 }
 
-func (a *AutoscalingGroupMonitor) refresh(autoscalingGroup *autoscaling.Group) error {
+// GetAllInstances return all the instances in AutoscalingGroupMonitor cache, sorted by instance ID
+func (a *AutoscalingGroupMonitor) GetAllInstances() []*InstanceMonitor {
+
+	instances := []*InstanceMonitor{}
+	for _, instanceMonitor := range a.instanceMonitors {
+		instances = append(instances, instanceMonitor)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].instanceID < instances[j].instanceID
+	})
+
+	return instances
+}
+
+// GetDesiredCapacity returns the desired capacity of the autoscaling group on the last refresh
+func (a *AutoscalingGroupMonitor) GetDesiredCapacity() int64 {
+	return a.desiredCapacity
+}
+
+func (a *AutoscalingGroupMonitor) refresh(autoscalingGroup *autoscaling.Group,
+	descriptions map[string]*ec2.Instance) error {
 
 	if err := a.enforceInstanceProtection(autoscalingGroup); err != nil {
 		return err
 	}
 
 	a.desiredCapacity = *autoscalingGroup.DesiredCapacity
+	a.tags = tagsMap(autoscalingGroup.Tags)
+	a.setPaused(a.tags[PausedTag] == "true")
 
 	// find new instances in autoscaling group
 	for _, instance := range autoscalingGroup.Instances {
 		_, ok := a.instanceMonitors[*instance.InstanceId]
 		if !ok {
-			if err := a.newInstance(instance); err != nil {
+			if err := a.newInstance(instance, descriptions[*instance.InstanceId]); err != nil {
 				log.Error(err)
 				continue
 			}
@@ -204,6 +627,9 @@ func (a *AutoscalingGroupMonitor) refresh(autoscalingGroup *autoscaling.Group) e
 
 	for instanceID := range a.instanceMonitors {
 		if instance, ok := findInstance(instanceID, autoscalingGroup); ok {
+			if instance.HealthStatus != nil {
+				a.instanceMonitors[*instance.InstanceId].healthStatus = *instance.HealthStatus
+			}
 			a.instanceMonitors[*instance.InstanceId].setLifecycleState(*instance.LifecycleState)
 		} else {
 			log.Debugf("Instance %s has disappeared from ASG %s. Stop monitoring it",
@@ -212,9 +638,107 @@ func (a *AutoscalingGroupMonitor) refresh(autoscalingGroup *autoscaling.Group) e
 		}
 	}
 
+	a.adoptTerminations()
+	a.deleteStaleLaunchHook()
+	a.cancelUnneededRemovals()
 	return nil
 }
 
+// adoptTerminations brings the instances that went to Terminating:Wait without being marked into the removal
+// flow, so they're drained before being destroyed. While removals are paused, they're left untouched and
+// adopted on the first refresh after resuming
+func (a *AutoscalingGroupMonitor) adoptTerminations() {
+
+	for _, instanceMonitor := range a.instanceMonitors {
+		if !instanceMonitor.isUnmarkedTermination() {
+			continue
+		}
+		if a.paused || a.ctx.IsPaused() {
+			log.Debugf("Removals paused. Termination of instance %s will be adopted once resumed",
+				instanceMonitor.instanceID)
+			continue
+		}
+		instanceMonitor.adoptTermination()
+	}
+}
+
+// IsPaused is true when the autoscaling group has the PausedTag set to "true"
+func (a *AutoscalingGroupMonitor) IsPaused() bool {
+	return a.paused
+}
+
+func (a *AutoscalingGroupMonitor) setPaused(paused bool) {
+
+	if paused == a.paused {
+		return
+	}
+	a.paused = paused
+
+	eventType := events.Resumed
+	if paused {
+		eventType = events.Paused
+	}
+	log.Infof("Removals on autoscaling group %s %s by tag %s", a.autoscalingGroupName, eventType, PausedTag)
+	a.ctx.Emit(events.Event{
+		Type:             eventType,
+		AutoscalingGroup: a.autoscalingGroupName,
+		Details:          map[string]interface{}{"source": "tag"},
+	})
+}
+
+// cancelUnneededRemovals rolls back the removal of the marked instances still InService when the desired
+// capacity has grown back, most recently marked first. Manually marked and quarantined instances are never
+// rolled back
+func (a *AutoscalingGroupMonitor) cancelUnneededRemovals() {
+
+	instances, markedInstances := a.countCapacityInstances()
+	neededInstances := int(a.desiredCapacity) - (instances - markedInstances)
+	if neededInstances <= 0 {
+		return
+	}
+
+	candidates := []*InstanceMonitor{}
+	for _, instanceMonitor := range a.getInstancesMarkedToBeRemoved() {
+		if instanceMonitor.lifecycleState == autoscaling.LifecycleStateInService &&
+			!instanceMonitor.manuallyMarked && !instanceMonitor.forceDestroy && !instanceMonitor.terminationRequested &&
+			!instanceMonitor.detached && !instanceMonitor.quarantined {
+			candidates = append(candidates, instanceMonitor)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].markTimestamp != candidates[j].markTimestamp {
+			return candidates[i].markTimestamp > candidates[j].markTimestamp
+		}
+		return candidates[i].instanceID < candidates[j].instanceID
+	})
+
+	for i := 0; i < neededInstances && i < len(candidates); i++ {
+		instanceMonitor := candidates[i]
+		log.Infof("Desired capacity of autoscaling group %s increased to %d. Cancelling removal of instance %s",
+			a.autoscalingGroupName, a.desiredCapacity, instanceMonitor.instanceID)
+
+		err := instanceMonitor.CancelRemoval()
+		instanceMonitor.EmitEvent(events.RemovalCancelled, map[string]interface{}{
+			"reason":          "desired capacity increased",
+			"desiredCapacity": a.desiredCapacity,
+		}, err)
+		if err != nil {
+			log.Errorf("Unable to cancel removal of instance %s: %s", instanceMonitor.instanceID, err)
+			continue
+		}
+		a.cancelledRemovals = append(a.cancelledRemovals, instanceMonitor)
+	}
+}
+
+// TakeCancelledRemovals returns the instances whose removal was cancelled since the last call, so their
+// maintenance can be ended
+func (a *AutoscalingGroupMonitor) TakeCancelledRemovals() []*InstanceMonitor {
+
+	cancelledRemovals := a.cancelledRemovals
+	a.cancelledRemovals = nil
+	return cancelledRemovals
+}
+
 func (a *AutoscalingGroupMonitor) setInstanceProtection(autoscalingGroup *autoscaling.Group) error {
 
 	log.Infof("Setting autoscaling %s and it's instances scaleInProtection flag",
@@ -225,7 +749,7 @@ func (a *AutoscalingGroupMonitor) setInstanceProtection(autoscalingGroup *autosc
 		instancesToProtect = append(instancesToProtect, instance.InstanceId)
 	}
 
-	err := a.ctx.AwsConn.SetASGInstanceProtection(autoscalingGroup.AutoScalingGroupName, instancesToProtect)
+	err := a.awsConn.SetASGInstanceProtection(autoscalingGroup.AutoScalingGroupName, instancesToProtect)
 	if err != nil {
 		return err
 	}
@@ -245,20 +769,19 @@ func (a *AutoscalingGroupMonitor) enforceInstanceProtection(autoscalingGroup *au
 	return nil
 }
 
-func (a *AutoscalingGroupMonitor) newInstance(instance *autoscaling.Instance) error {
+func (a *AutoscalingGroupMonitor) newInstance(instance *autoscaling.Instance, description *ec2.Instance) error {
 
 	log.WithFields(log.Fields{
 		"autoscaling_group": a.autoscalingGroupName,
 		"instance":          *instance.InstanceId,
 	}).Debugf("Found new instance to monitor")
 
-	instanceMonitor, err := newInstanceMonitor(
-		a.ctx, a.autoscalingGroupName, *instance.InstanceId, *instance.LifecycleState, true)
-	if err != nil {
-		return err
+	if description == nil {
+		return fmt.Errorf("No instance information found for instance id %v", *instance.InstanceId)
 	}
 
-	a.instanceMonitors[*instance.InstanceId] = instanceMonitor
+	a.instanceMonitors[*instance.InstanceId] = newInstanceMonitorFromDescription(
+		a.ctx, a.awsConn, a.autoscalingGroupName, *instance.InstanceId, *instance.LifecycleState, true, description)
 	return nil
 }
 
@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"testing"
+	"time"
 
 	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
@@ -62,6 +63,21 @@ func TestUndesiredInstances(t *testing.T) {
 				So(monitor.GetNumUndesiredInstances(), ShouldEqual, 1)
 			})
 		})
+		Convey("if it has 3 instances, one of them in Standby, and desired instances are 2", func() {
+			monitor := newTestMonitor(&aws.ConnectionMock{
+				Records: map[string]*[]string{
+					"DescribeInstanceById": {"default", "default", "default"},
+					"DescribeAGByName":     {"standby"},
+				},
+			})
+
+			Convey("it should have no undesired instances", func() {
+				So(monitor.GetNumUndesiredInstances(), ShouldEqual, 0)
+			})
+			Convey("the instance in Standby should not be a removal candidate", func() {
+				So(monitor.GetInstances(), ShouldHaveLength, 2)
+			})
+		})
 	})
 }
 
@@ -121,6 +137,47 @@ func TestRefresh(t *testing.T) {
 	})
 }
 
+func TestCancelUnneededRemovals(t *testing.T) {
+
+	Convey("When the desired capacity of an AutoscalingGroup goes back up", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {"default", "default", "default"},
+				"DescribeAGByName":     {"two_undesired_hosts", "one_undesired_host"},
+			},
+		}
+		monitors := newTestAutoscalingMonitors(awsConn)
+		monitor := monitors.GetAutoscalingGroupMonitorsList()[0]
+
+		olderMarked := monitor.instanceMonitors["i-34719eb8"]
+		olderMarked.TagToBeRemoved()
+		olderMarked.markTimestamp = 100
+		newerMarked := monitor.instanceMonitors["i-446a73cf"]
+		newerMarked.TagToBeRemoved()
+		newerMarked.markTimestamp = 200
+		awsConn.Requests = nil
+
+		monitors.Refresh()
+		Convey("the most recently marked instance should not be marked anymore", func() {
+			So(newerMarked.IsMarkedToBeRemoved(), ShouldBeFalse)
+			So(olderMarked.IsMarkedToBeRemoved(), ShouldBeTrue)
+			So(monitor.GetNumUndesiredInstances(), ShouldEqual, 0)
+		})
+		Convey("its protection should have been restored and its tag removed", func() {
+			So(awsConn.Requests["SetASGInstanceProtection"], ShouldContain,
+				[]string{"some-Autoscaling-Group", "i-446a73cf"})
+			So(awsConn.Requests["DeleteInstanceTag"], ShouldResemble,
+				[][]string{{"DEATH_NODE_MARK", "i-446a73cf"}})
+		})
+		Convey("it should be returned once as a cancelled removal", func() {
+			cancelled := monitor.TakeCancelledRemovals()
+			So(len(cancelled), ShouldEqual, 1)
+			So(*cancelled[0].InstanceID(), ShouldEqual, "i-446a73cf")
+			So(monitor.TakeCancelledRemovals(), ShouldBeEmpty)
+		})
+	})
+}
+
 func TestInitializeAutoscalingGroup(t *testing.T) {
 
 	Convey("When creating an AutoscalingGroup", t, func() {
@@ -128,7 +185,6 @@ func TestInitializeAutoscalingGroup(t *testing.T) {
 			Records: map[string]*[]string{
 				"DescribeInstanceById": {"default", "default", "default"},
 				"DescribeAGByName":     {"instance_profile_disabled"},
-				"HasLifeCycleHook":     {"false"},
 			},
 		}
 		newTestAutoscalingMonitors(awsConn)
@@ -146,6 +202,116 @@ func TestInitializeAutoscalingGroup(t *testing.T) {
 			So(len(callArguments[0]), ShouldBeGreaterThanOrEqualTo, 1)
 			So(callArguments[0][0], ShouldEqual, "some-Autoscaling-Group")
 			So(callArguments[0][1], ShouldEqual, "3600")
+			So(callArguments[0][2], ShouldEqual, "CONTINUE")
+		})
+	})
+}
+
+func TestRefreshDetachRemovalMode(t *testing.T) {
+
+	Convey("When an autoscaling group uses the detach removal mode", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {"default", "default", "default"},
+				"DescribeAGByName":     {"instance_profile_disabled", "instance_profile_disabled"},
+			},
+		}
+		monitors := newTestAutoscalingMonitors(awsConn)
+		monitors.ctx.Conf.RemovalMode = context.RemovalDetach
+		awsConn.FlushMock()
+		monitors.Refresh()
+
+		Convey("the scale-in protection of its instances should still be enforced", func() {
+			So(awsConn.Requests["SetASGInstanceProtection"], ShouldNotBeNil)
+			So(awsConn.Requests["SetASGInstanceProtection"][0][1], ShouldEqual, "i-34719eb8")
+		})
+	})
+}
+
+func TestAdoptTerminations(t *testing.T) {
+
+	Convey("When an instance goes to Terminating:Wait without being marked on a paused autoscaling group", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {"default", "default", "default"},
+				"DescribeAGByName":     {"paused_one_terminating", "unhealthy_terminating", "unhealthy_terminating"},
+			},
+		}
+		monitors := newTestAutoscalingMonitors(awsConn)
+		instanceMonitor, _ := monitors.GetInstanceByID("i-34719eb8")
+
+		Convey("its termination should not be adopted", func() {
+			So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+			So(instanceMonitor.IsMarkedToBeRemoved(), ShouldBeFalse)
+		})
+		Convey("its termination should not be adopted while removals are paused globally", func() {
+			monitors.ctx.Paused = func() bool { return true }
+			monitors.Refresh()
+			So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+			So(instanceMonitor.IsMarkedToBeRemoved(), ShouldBeFalse)
+
+			Convey("and it should be adopted once resumed", func() {
+				monitors.ctx.Paused = nil
+				monitors.Refresh()
+				So(awsConn.Requests["SetInstanceTag"], ShouldHaveLength, 1)
+				So(instanceMonitor.IsExternalTermination(), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestReconcileLifecycleHooks(t *testing.T) {
+
+	Convey("When an autoscaling group already has the deathnode lifecycle hook", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":  {"default", "default", "default"},
+				"DescribeAGByName":      {"default"},
+				"DescribeLifeCycleHook": {"default", "default", "default"},
+			},
+		}
+		monitors := newTestAutoscalingMonitors(awsConn)
+
+		Convey("it should not be put again if it matches the settings", func() {
+			So(awsConn.Requests["PutLifeCycleHook"], ShouldBeNil)
+		})
+		Convey("it should be put again on reload if its default result drifted", func() {
+			monitors.ctx.Conf.LifecycleDefaultResult = "ABANDON"
+			monitors.Reload()
+			So(awsConn.Requests["PutLifeCycleHook"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "3600", "ABANDON"}})
+		})
+		Convey("it should be put again on reload if its timeout drifted", func() {
+			monitors.ctx.Conf.LifecycleTimeout = 7200
+			monitors.Reload()
+			So(awsConn.Requests["PutLifeCycleHook"], ShouldResemble, [][]string{
+				{"some-Autoscaling-Group", "7200", "CONTINUE"}})
+		})
+		Convey("it should not be put on reload with the detach removal mode", func() {
+			monitors.ctx.Conf.LifecycleTimeout = 7200
+			monitors.ctx.Conf.RemovalMode = context.RemovalDetach
+			monitors.Reload()
+			So(awsConn.Requests["PutLifeCycleHook"], ShouldBeNil)
+		})
+	})
+	Convey("When an autoscaling group has the launch lifecycle hook but launchTimeout is disabled", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById":        {"node1", "node2", "node_unregistered", "node_unregistered_old"},
+				"DescribeAGByName":            {"pending_launches"},
+				"DescribeLifeCycleHook":       {"default"},
+				"DescribeLaunchLifeCycleHook": {"launch_hook"},
+			},
+		}
+		newTestAutoscalingMonitors(awsConn)
+
+		Convey("the launch of the instances in Pending:Wait should be completed before deleting it", func() {
+			So(awsConn.Requests["PutLaunchLifeCycleHook"], ShouldBeNil)
+			So(awsConn.Requests["CompleteLaunchLifecycleAction"], ShouldNotBeEmpty)
+			for _, request := range awsConn.Requests["CompleteLaunchLifecycleAction"] {
+				So(request[2], ShouldEqual, "CONTINUE")
+			}
+			So(awsConn.Requests["DeleteLaunchLifeCycleHook"], ShouldResemble, [][]string{{"some-Autoscaling-Group"}})
 		})
 	})
 }
@@ -179,6 +345,35 @@ func TestGetInstances(t *testing.T) {
 	})
 }
 
+func TestPendingLaunches(t *testing.T) {
+
+	Convey("When an autoscaling group has instances waiting for their launch to be completed", t, func() {
+		monitor := newTestMonitor(&aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {"node1", "node2", "node_unregistered", "node_unregistered_old"},
+				"DescribeAGByName":     {"pending_launches"},
+			},
+		})
+		monitor.desiredCapacity = 0
+
+		Convey("they should not be candidates to be removed", func() {
+			So(monitor.GetInstances(), ShouldHaveLength, 1)
+			So(*monitor.GetInstances()[0].InstanceID(), ShouldEqual, "i-34719eb8")
+		})
+		Convey("they should not count on the capacity of the autoscaling group", func() {
+			So(monitor.GetNumUndesiredInstances(), ShouldEqual, 1)
+			monitor.desiredCapacity = 1
+			So(monitor.GetNumUndesiredInstances(), ShouldEqual, 0)
+			So(monitor.IsAboveDesiredCapacity(), ShouldBeFalse)
+		})
+		Convey("once their launch is completed, they should be counted", func() {
+			monitor.instanceMonitors["i-446a73cf"].launchCompleted = true
+			So(monitor.GetInstances(), ShouldHaveLength, 2)
+			So(monitor.GetNumUndesiredInstances(), ShouldEqual, 2)
+		})
+	})
+}
+
 func TestGetInstanceById(t *testing.T) {
 
 	Convey("When an autoscaling group with 3 instances is created", t, func() {
@@ -200,6 +395,199 @@ func TestGetInstanceById(t *testing.T) {
 	})
 }
 
+func TestAutoscalingGroupSelectors(t *testing.T) {
+
+	Convey("When monitoring autoscaling groups from the config file", t, func() {
+		lifecycleTimeout := 7200
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"default", "default", "default", "default", "default", "default"},
+				"DescribeAGByName": {"two_asg", "two_asg"},
+			},
+		}
+		ctx := &context.ApplicationContext{
+			AwsConn: awsConn,
+			Conf: context.ApplicationConf{
+				DeathNodeMark:    "DEATH_NODE_MARK",
+				LifecycleTimeout: 3600,
+				AutoscalingGroups: []*context.AutoscalingGroupConf{
+					{Regexp: "^some-Autoscaling-Group$", LifecycleTimeout: &lifecycleTimeout},
+					{Prefix: "some-Autoscaling"},
+				},
+			},
+			Clock: clock.New(),
+		}
+		monitors := NewAutoscalingServiceMonitor(ctx)
+		monitors.Refresh()
+
+		Convey("each autoscaling group should be monitored only by the first selector matching it", func() {
+			regexpMonitor, _ := monitors.GetAutoscalingGroupMonitor("some-Autoscaling-Group")
+			So(regexpMonitor.Conf().ID(), ShouldEqual, "regexp:^some-Autoscaling-Group$")
+			So(monitors.autoscalingMonitors["some-Autoscaling"], ShouldHaveLength, 1)
+			So(monitors.autoscalingMonitors["some-Autoscaling"], ShouldNotContainKey, "some-Autoscaling-Group")
+		})
+		Convey("the lifecycleHook should use the autoscaling group settings", func() {
+			callArguments := awsConn.Requests["PutLifeCycleHook"]
+			So(callArguments, ShouldHaveLength, 2)
+			for _, arguments := range callArguments {
+				if arguments[0] == "some-Autoscaling-Group" {
+					So(arguments[1], ShouldEqual, "7200")
+				} else {
+					So(arguments[1], ShouldEqual, "3600")
+				}
+			}
+		})
+	})
+}
+
+func TestAutoscalingGroupTagSelectors(t *testing.T) {
+
+	Convey("When monitoring autoscaling groups selected by tags", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"default", "default", "default", "default", "default", "default"},
+				"DescribeAGByName":      {"two_asg_tagged", "two_asg_tagged"},
+				"DescribeLifeCycleHook": {"default", "default"},
+			},
+		}
+		ctx := &context.ApplicationContext{
+			AwsConn: awsConn,
+			Conf: context.ApplicationConf{
+				DeathNodeMark:    "DEATH_NODE_MARK",
+				LifecycleTimeout: 3600,
+				AutoscalingGroups: []*context.AutoscalingGroupConf{
+					{Tags: map[string]string{"mesos-cluster": "prod"}},
+					{Prefix: "some-Autoscaling"},
+				},
+			},
+			Clock: clock.New(),
+		}
+		monitors := NewAutoscalingServiceMonitor(ctx)
+		monitors.Refresh()
+
+		Convey("the autoscaling groups should be described by their tags", func() {
+			So(awsConn.Requests["DescribeAGsByTags"], ShouldResemble, [][]string{{"mesos-cluster=prod"}})
+		})
+		Convey("only the autoscaling groups with the same tag values should be monitored by the selector", func() {
+			tagsMonitor, _ := monitors.GetAutoscalingGroupMonitor("some-Autoscaling-Group")
+			So(tagsMonitor.Conf().ID(), ShouldEqual, "tags:mesos-cluster=prod")
+			prefixMonitor, _ := monitors.GetAutoscalingGroupMonitor("some-Autoscaling-Group2")
+			So(prefixMonitor.Conf().ID(), ShouldEqual, "some-Autoscaling")
+		})
+		Convey("the autoscaling groups should keep their selector on reload", func() {
+			monitors.Reload()
+			tagsMonitor, _ := monitors.GetAutoscalingGroupMonitor("some-Autoscaling-Group")
+			So(tagsMonitor.Conf().ID(), ShouldEqual, "tags:mesos-cluster=prod")
+		})
+	})
+}
+
+func TestAutoscalingGroupTargets(t *testing.T) {
+
+	Convey("When monitoring autoscaling groups on different AWS targets", t, func() {
+		newConn := func() *aws.ConnectionMock {
+			return &aws.ConnectionMock{
+				Records: map[string]*[]string{
+					"DescribeInstanceById": {"default", "default", "default", "default", "default", "default"},
+					"DescribeAGByName":     {"default", "default"},
+				},
+			}
+		}
+		defaultConn, targetConn := newConn(), newConn()
+		createdConns := 0
+		ctx := &context.ApplicationContext{
+			AwsConn: defaultConn,
+			AwsConnFactory: func(target context.AWSTarget) (aws.ClientInterface, error) {
+				createdConns++
+				return targetConn, nil
+			},
+			Conf: context.ApplicationConf{
+				DeathNodeMark:    "DEATH_NODE_MARK",
+				LifecycleTimeout: 3600,
+				AutoscalingGroups: []*context.AutoscalingGroupConf{
+					{Prefix: "some-Autoscaling", Region: "us-east-1", RoleARN: "arn:aws:iam::123456789012:role/deathnode"},
+					{Prefix: "some-Autoscaling"},
+				},
+			},
+			Clock: clock.New(),
+		}
+		monitors := NewAutoscalingServiceMonitor(ctx)
+		monitors.Refresh()
+		monitors.Refresh()
+
+		Convey("autoscaling groups with the same name on both targets should be monitored", func() {
+			monitorsList := monitors.GetAutoscalingGroupMonitorsList()
+			So(monitorsList, ShouldHaveLength, 2)
+			So(monitorsList[0].Conf().ID(), ShouldEqual, "some-Autoscaling@us-east-1/arn:aws:iam::123456789012:role/deathnode")
+			So(monitorsList[0].awsConn, ShouldEqual, targetConn)
+			So(monitorsList[1].awsConn, ShouldEqual, defaultConn)
+		})
+		Convey("each autoscaling group should be managed through the connection of its target", func() {
+			So(createdConns, ShouldEqual, 1)
+			So(defaultConn.Requests["PutLifeCycleHook"], ShouldHaveLength, 1)
+			So(targetConn.Requests["PutLifeCycleHook"], ShouldHaveLength, 1)
+			instanceMonitor := monitors.GetAutoscalingGroupMonitorsList()[0].GetAllInstances()[0]
+			So(instanceMonitor.AwsConn(), ShouldEqual, targetConn)
+		})
+		Convey("the connections of all the targets should be listed", func() {
+			So(ctx.AwsConns(), ShouldResemble, []aws.ClientInterface{defaultConn, targetConn})
+		})
+	})
+}
+
+func TestAutoscalingInstancesDescription(t *testing.T) {
+
+	Convey("When refreshing autoscaling groups with new instances", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {
+					"default", "default", "default", "default", "default", "default",
+					"node_with_tag", "node_with_tag", "node_with_tag",
+					"node_with_tag", "node_with_tag", "node_with_tag"},
+				"DescribeAGByName": {"two_asg", "two_asg", "two_asg"},
+			},
+		}
+		clockMock := clock.NewMock()
+		ctx := &context.ApplicationContext{
+			AwsConn: awsConn,
+			Conf: context.ApplicationConf{
+				DeathNodeMark:          "DEATH_NODE_MARK",
+				LifecycleTimeout:       3600,
+				InstanceRefreshSeconds: 60,
+				AutoscalingGroups:      []*context.AutoscalingGroupConf{{Prefix: "some-Autoscaling"}},
+			},
+			Clock: clockMock,
+		}
+		monitors := NewAutoscalingServiceMonitor(ctx)
+		monitors.Refresh()
+
+		Convey("the instances of all the autoscaling groups should be described together", func() {
+			So(awsConn.Requests["DescribeInstancesByIDs"], ShouldHaveLength, 1)
+			So(awsConn.Requests["DescribeInstancesByIDs"][0], ShouldHaveLength, 6)
+		})
+		Convey("known instances should not be described again before the refresh interval", func() {
+			awsConn.FlushMock()
+			clockMock.Add(30 * time.Second)
+			monitors.Refresh()
+			So(awsConn.Requests["DescribeInstancesByIDs"], ShouldBeEmpty)
+		})
+		Convey("known instances should be refreshed in bulk after the refresh interval", func() {
+			awsConn.FlushMock()
+			clockMock.Add(61 * time.Second)
+			monitors.Refresh()
+			So(awsConn.Requests["DescribeInstancesByIDs"], ShouldHaveLength, 1)
+			for _, autoscalingGroupMonitor := range monitors.GetAutoscalingGroupMonitorsList() {
+				for _, instanceMonitor := range autoscalingGroupMonitor.GetAllInstances() {
+					So(instanceMonitor.TagRemovalTimestamp(), ShouldEqual, 1190995200)
+					So(instanceMonitor.IsMarkedToBeRemoved(), ShouldBeTrue)
+				}
+			}
+		})
+	})
+}
+
 func newTestMonitor(awsConn *aws.ConnectionMock) *AutoscalingGroupMonitor {
 
 	return newTestAutoscalingMonitors(awsConn).GetAutoscalingGroupMonitorsList()[0]
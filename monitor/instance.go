@@ -3,8 +3,12 @@ package monitor
 import (
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
+	"github.com/alanbover/deathnode/metrics"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	log "github.com/sirupsen/logrus"
 )
@@ -13,41 +17,133 @@ import (
 // confirmation to be removed
 const LifecycleStateTerminatingWait = "Terminating:Wait"
 
+// LifecycleStatePendingWait defines the state of an instance in the autoscalingGroup when it's waiting for
+// the launch lifecycle hook to be completed
+const LifecycleStatePendingWait = "Pending:Wait"
+
+// LifecycleStateStandby defines the state of an instance in the autoscalingGroup when it's kept running out of
+// service, not counted on its capacity
+const LifecycleStateStandby = "Standby"
+
+// maxLifecycleGlobalTimeout is the maximum time AWS keeps an instance on a lifecycle action, regardless of
+// its heartbeats
+const maxLifecycleGlobalTimeout = 48 * time.Hour
+
+// LifecycleGlobalTimeout returns the time AWS keeps an instance on a lifecycle action with the heartbeat
+// timeout before applying its default result, regardless of its heartbeats: 100 times the heartbeat timeout,
+// up to 48 hours
+func LifecycleGlobalTimeout(heartbeatTimeout int) time.Duration {
+
+	globalTimeout := 100 * time.Duration(heartbeatTimeout) * time.Second
+	if globalTimeout > maxLifecycleGlobalTimeout {
+		return maxLifecycleGlobalTimeout
+	}
+	return globalTimeout
+}
+
+// HealthStatusUnhealthy is the health status of the instances the autoscaling group considers unhealthy
+const HealthStatusUnhealthy = "Unhealthy"
+
+// ManualMarkSuffix is appended to the DeathNodeMark tag to flag the instances manually marked to be removed
+const ManualMarkSuffix = "_MANUAL"
+
+// DetachedMarkSuffix is appended to the DeathNodeMark tag to flag the instances detached from their autoscaling
+// group, so they are still terminated once they are not part of it anymore
+const DetachedMarkSuffix = "_DETACHED"
+
+// QuarantineMarkSuffix is appended to the DeathNodeMark tag to flag the instances to be moved to Standby once
+// drained, instead of being terminated
+const QuarantineMarkSuffix = "_QUARANTINE"
+
+// MarkedAtSuffix is appended to the DeathNodeMark tag to store when the instance was first marked to be removed.
+// Unlike the DeathNodeMark tag, it's not rewritten when the lifecycle hook is refreshed
+const MarkedAtSuffix = "_MARKED_AT"
+
+// TerminatingWaitSuffix is appended to the DeathNodeMark tag to store when the instance entered Terminating:Wait
+const TerminatingWaitSuffix = "_TERMINATING_WAIT"
+
 // InstanceMonitor monitors an AWS instance
 type InstanceMonitor struct {
-	autoscalingGroupID  string
-	launchConfiguration string
-	ipAddress           string
-	instanceID          string
-	lifecycleState      string
-	isProtected         bool
-	tagRemovalTimestamp int64
-	ctx                 *context.ApplicationContext
+	autoscalingGroupID   string
+	launchConfiguration  string
+	ipAddress            string
+	instanceID           string
+	lifecycleState       string
+	isProtected          bool
+	tagRemovalTimestamp  int64
+	markTimestamp        int64
+	manuallyMarked       bool
+	forceDestroy         bool
+	terminationRequested bool
+	detached             bool
+	quarantined          bool
+	standbyRequested     bool
+	lifecycleActionToken string
+	launchTime           time.Time
+	launchCompleted      bool
+	drainFailed          bool
+	maintenanceScheduled bool
+	terminatingWaitSince time.Time
+	terminatingWaitTag   int64
+	deadlineAlerted      bool
+	healthStatus         string
+	externalTermination  bool
+	awsConn              aws.ClientInterface
+	ctx                  *context.ApplicationContext
 }
 
-func newInstanceMonitor(ctx *context.ApplicationContext, autoscalingGroupID, instanceID, lifecycleState string,
-	isProtected bool) (*InstanceMonitor, error) {
+func newInstanceMonitor(ctx *context.ApplicationContext, awsConn aws.ClientInterface, autoscalingGroupID,
+	instanceID, lifecycleState string, isProtected bool) (*InstanceMonitor, error) {
 
-	response, err := ctx.AwsConn.DescribeInstanceByID(instanceID)
+	response, err := awsConn.DescribeInstanceByID(instanceID)
 
 	if err != nil {
 		return &InstanceMonitor{}, err
 	}
 
+	return newInstanceMonitorFromDescription(ctx, awsConn, autoscalingGroupID, instanceID, lifecycleState,
+		isProtected, response), nil
+}
+
+// newInstanceMonitorFromDescription returns an InstanceMonitor for an instance already described by EC2
+func newInstanceMonitorFromDescription(ctx *context.ApplicationContext, awsConn aws.ClientInterface,
+	autoscalingGroupID, instanceID, lifecycleState string, isProtected bool, response *ec2.Instance) *InstanceMonitor {
+
 	tagRemovalTimestamp, err := getTagRemovalTimestamp(response.Tags, ctx.Conf.DeathNodeMark)
 	if err != nil {
 		log.Warn("Invalid value found for tag %s on instance %s", ctx.Conf.DeathNodeMark, instanceID)
 	}
+	terminatingWaitTag, err := getTagRemovalTimestamp(response.Tags, ctx.Conf.DeathNodeMark+TerminatingWaitSuffix)
+	if err != nil {
+		log.Warnf("Invalid value found for tag %s on instance %s", ctx.Conf.DeathNodeMark+TerminatingWaitSuffix,
+			instanceID)
+	}
+
+	launchTime := time.Time{}
+	if response.LaunchTime != nil {
+		launchTime = *response.LaunchTime
+	}
 
 	return &InstanceMonitor{
 		autoscalingGroupID:  autoscalingGroupID,
+		launchTime:          launchTime,
 		ipAddress:           *response.PrivateIpAddress,
 		instanceID:          instanceID,
 		lifecycleState:      lifecycleState,
 		isProtected:         isProtected,
+		awsConn:             awsConn,
 		ctx:                 ctx,
 		tagRemovalTimestamp: tagRemovalTimestamp,
-	}, nil
+		markTimestamp:       getMarkTimestamp(response.Tags, ctx.Conf.DeathNodeMark, tagRemovalTimestamp),
+		terminatingWaitTag:  terminatingWaitTag,
+		manuallyMarked:      tagRemovalTimestamp != 0 && hasTag(response.Tags, ctx.Conf.DeathNodeMark+ManualMarkSuffix),
+		quarantined:         tagRemovalTimestamp != 0 && hasTag(response.Tags, ctx.Conf.DeathNodeMark+QuarantineMarkSuffix),
+	}
+}
+
+// AwsConn returns the AWS connection for the region and account of the instance
+func (a *InstanceMonitor) AwsConn() aws.ClientInterface {
+	return a.awsConn
 }
 
 // IP returns the private IP of the AWS instance
@@ -60,6 +156,13 @@ func (a *InstanceMonitor) TagRemovalTimestamp() int64 {
 	return a.tagRemovalTimestamp
 }
 
+// MarkTimestamp returns the timestamp the instance was first tagged to be removed. Unlike TagRemovalTimestamp,
+// it's not updated when the lifecycle hook is refreshed. For instances found already tagged, it's read from
+// their MarkedAtSuffix tag, or their DeathNodeMark tag value if they don't have it
+func (a *InstanceMonitor) MarkTimestamp() int64 {
+	return a.markTimestamp
+}
+
 // LifecycleState returns the lifeCycleState of the instance in the ASG
 func (a *InstanceMonitor) LifecycleState() string {
 	return a.lifecycleState
@@ -77,7 +180,8 @@ func (a *InstanceMonitor) AutoscalingGroupID() *string {
 
 // RemoveInstanceProtection removes the instance protection for the autoscaling
 func (a *InstanceMonitor) RemoveInstanceProtection() error {
-	err := a.ctx.AwsConn.RemoveASGInstanceProtection(&a.autoscalingGroupID, &a.instanceID)
+	err := a.awsConn.RemoveASGInstanceProtection(&a.autoscalingGroupID, &a.instanceID)
+	a.EmitEvent(events.ProtectionRemoved, nil, err)
 	if err != nil {
 		return err
 	}
@@ -94,12 +198,287 @@ func (a *InstanceMonitor) IsProtected() bool {
 // Key: valueOf(DEATH_NODE_TAG_MARK)
 // Value: Current timestamp (epoch)
 func (a *InstanceMonitor) TagToBeRemoved() error {
+	err := a.setRemovalTag()
+	if err == nil {
+		metrics.TagsSet.Inc(a.autoscalingGroupID)
+	}
+	a.EmitEvent(events.TagApplied, map[string]interface{}{
+		"tag":   a.ctx.Conf.DeathNodeMark,
+		"value": a.tagRemovalTimestamp,
+	}, err)
+	return err
+}
+
+// retagToBeRemoved rewrites the removal tag of a marked instance with the current timestamp. Unlike
+// TagToBeRemoved, it's neither counted nor emits an event, as it's not a removal decision
+func (a *InstanceMonitor) retagToBeRemoved() error {
+	return a.setRemovalTag()
+}
+
+// setRemovalTag sets the removal tag of the instance to the current timestamp, keeping its first mark time
+func (a *InstanceMonitor) setRemovalTag() error {
 	currentTimestamp := a.ctx.Clock.Now().Unix()
-	err := a.ctx.AwsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark, fmt.Sprintf("%v", currentTimestamp), a.instanceID)
+	err := a.awsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark, fmt.Sprintf("%v", currentTimestamp), a.instanceID)
 	a.tagRemovalTimestamp = currentTimestamp
+	if a.markTimestamp == 0 {
+		a.markTimestamp = currentTimestamp
+	}
 	return err
 }
 
+// MarkManually tags the instance to be removed, flagging it as manually marked. Once drained, manually
+// marked instances are terminated through their autoscaling group, which launches a replacement
+func (a *InstanceMonitor) MarkManually() error {
+
+	if err := a.TagToBeRemoved(); err != nil {
+		return err
+	}
+
+	err := a.awsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark+ManualMarkSuffix, "true", a.instanceID)
+	if err != nil {
+		return err
+	}
+	a.manuallyMarked = true
+	return nil
+}
+
+// IsManuallyMarked is true when the instance was marked for removal by an operator
+func (a *InstanceMonitor) IsManuallyMarked() bool {
+	return a.manuallyMarked
+}
+
+// Quarantine tags the instance to be removed, flagging it as quarantined. Once drained, quarantined instances
+// are moved to Standby instead of being terminated, so they keep running out of the cluster
+func (a *InstanceMonitor) Quarantine() error {
+
+	if !a.IsMarkedToBeRemoved() {
+		if err := a.TagToBeRemoved(); err != nil {
+			return err
+		}
+	}
+
+	err := a.awsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark+QuarantineMarkSuffix, "true", a.instanceID)
+	if err != nil {
+		return err
+	}
+	a.quarantined = true
+	return nil
+}
+
+// IsQuarantined is true when the instance was quarantined by an operator
+func (a *InstanceMonitor) IsQuarantined() bool {
+	return a.quarantined
+}
+
+// EnterStandby moves the instance to Standby. If shouldDecrementDesiredCapacity is false, the autoscaling group
+// launches a replacement. It's only called once
+func (a *InstanceMonitor) EnterStandby(shouldDecrementDesiredCapacity bool) error {
+
+	if a.standbyRequested {
+		return nil
+	}
+
+	if err := a.awsConn.EnterStandby(&a.autoscalingGroupID, &a.instanceID, shouldDecrementDesiredCapacity); err != nil {
+		return err
+	}
+	a.standbyRequested = true
+	return nil
+}
+
+// IsStandbyRequested is true when the instance has been requested to enter Standby, even if AWS has not moved
+// it yet
+func (a *InstanceMonitor) IsStandbyRequested() bool {
+	return a.standbyRequested
+}
+
+// ExitStandby moves the instance back to service. AWS increments the desired capacity of its autoscaling group
+func (a *InstanceMonitor) ExitStandby() error {
+
+	if err := a.awsConn.ExitStandby(&a.autoscalingGroupID, &a.instanceID); err != nil {
+		return err
+	}
+	a.standbyRequested = false
+	return nil
+}
+
+// CancelRemoval restores the scale-in protection of the instance and removes its removal tags
+func (a *InstanceMonitor) CancelRemoval() error {
+
+	err := a.awsConn.SetASGInstanceProtection(&a.autoscalingGroupID, []*string{&a.instanceID})
+	if err != nil {
+		return err
+	}
+	a.isProtected = true
+
+	if err := a.awsConn.DeleteInstanceTag(a.ctx.Conf.DeathNodeMark, a.instanceID); err != nil {
+		return err
+	}
+	if a.manuallyMarked {
+		if err := a.awsConn.DeleteInstanceTag(a.ctx.Conf.DeathNodeMark+ManualMarkSuffix, a.instanceID); err != nil {
+			return err
+		}
+	}
+	if a.quarantined {
+		if err := a.awsConn.DeleteInstanceTag(a.ctx.Conf.DeathNodeMark+QuarantineMarkSuffix, a.instanceID); err != nil {
+			return err
+		}
+	}
+
+	a.tagRemovalTimestamp = 0
+	a.markTimestamp = 0
+	a.manuallyMarked = false
+	a.forceDestroy = false
+	a.terminationRequested = false
+	a.drainFailed = false
+	a.maintenanceScheduled = false
+	a.externalTermination = false
+	a.quarantined = false
+	a.standbyRequested = false
+	return nil
+}
+
+// ForceDestroy makes the instance to be destroyed without waiting for its tasks to finish
+func (a *InstanceMonitor) ForceDestroy() {
+	a.forceDestroy = true
+}
+
+// IsForcedDestroy is true when the instance should be destroyed without waiting for its tasks to finish
+func (a *InstanceMonitor) IsForcedDestroy() bool {
+	return a.forceDestroy
+}
+
+// RequestTermination terminates the instance through its autoscaling group, without decrementing its
+// desired capacity, so the termination lifecycle hook moves it to Terminating:Wait. It's only called once
+func (a *InstanceMonitor) RequestTermination() error {
+
+	if a.terminationRequested {
+		return nil
+	}
+
+	if err := a.awsConn.TerminateASGInstance(&a.instanceID, false); err != nil {
+		return err
+	}
+	a.terminationRequested = true
+	return nil
+}
+
+// Detach removes the instance from its autoscaling group without terminating it, so no lifecycle hook applies.
+// If shouldDecrementDesiredCapacity is false, the autoscaling group launches a replacement. The instance is
+// tagged as detached before, so it's terminated even if it's already out of the autoscaling group when the
+// detach fails. It's only called once
+func (a *InstanceMonitor) Detach(shouldDecrementDesiredCapacity bool) error {
+
+	if a.detached {
+		return nil
+	}
+
+	if err := a.awsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark+DetachedMarkSuffix, "true", a.instanceID); err != nil {
+		return err
+	}
+
+	err := a.awsConn.DetachInstance(&a.autoscalingGroupID, &a.instanceID, shouldDecrementDesiredCapacity)
+	if err != nil {
+		return err
+	}
+	a.detached = true
+	return nil
+}
+
+// IsDetached is true when the instance has been detached from its autoscaling group
+func (a *InstanceMonitor) IsDetached() bool {
+	return a.detached
+}
+
+// Terminate terminates the EC2 instance
+func (a *InstanceMonitor) Terminate() error {
+	return a.awsConn.TerminateInstance(&a.instanceID)
+}
+
+// SetLifecycleActionToken stores the token of the lifecycle action, received with its notification
+func (a *InstanceMonitor) SetLifecycleActionToken(lifecycleActionToken string) {
+	a.lifecycleActionToken = lifecycleActionToken
+}
+
+// LifecycleActionToken returns the token of the current lifecycle action, or nil if it's not known
+func (a *InstanceMonitor) LifecycleActionToken() *string {
+
+	if a.lifecycleActionToken == "" {
+		return nil
+	}
+	return &a.lifecycleActionToken
+}
+
+// LaunchTime returns the time the instance was launched, or the zero time if EC2 didn't report it
+func (a *InstanceMonitor) LaunchTime() time.Time {
+	return a.launchTime
+}
+
+// SetLaunchTime sets the time the instance was launched, for the instances EC2 didn't report it
+func (a *InstanceMonitor) SetLaunchTime(launchTime time.Time) {
+	a.launchTime = launchTime
+}
+
+// IsLaunchCompleted is true once the launch lifecycle action of the instance has been completed
+func (a *InstanceMonitor) IsLaunchCompleted() bool {
+	return a.launchCompleted
+}
+
+// isLaunchPending is true while the instance waits in Pending:Wait for its launch lifecycle action to be completed
+func (a *InstanceMonitor) isLaunchPending() bool {
+	return a.lifecycleState == LifecycleStatePendingWait && !a.launchCompleted
+}
+
+// CompleteLaunch completes the launch lifecycle action of the instance with the result (CONTINUE or
+// ABANDON). It's only called once per instance
+func (a *InstanceMonitor) CompleteLaunch(result string) error {
+
+	err := a.awsConn.CompleteLaunchLifecycleAction(&a.autoscalingGroupID, &a.instanceID, result)
+	if err != nil {
+		return err
+	}
+	a.launchCompleted = true
+	return nil
+}
+
+// ContinueLifecycleAction completes the lifecycle action of the instance with CONTINUE, letting AWS terminate it
+func (a *InstanceMonitor) ContinueLifecycleAction() error {
+	return a.awsConn.CompleteLifecycleAction(
+		&a.autoscalingGroupID, &a.instanceID, a.LifecycleActionToken(), aws.LifecycleActionContinue)
+}
+
+// AbandonLifecycleAction completes the lifecycle action of the instance with ABANDON
+func (a *InstanceMonitor) AbandonLifecycleAction() error {
+	return a.awsConn.CompleteLifecycleAction(
+		&a.autoscalingGroupID, &a.instanceID, a.LifecycleActionToken(), aws.LifecycleActionAbandon)
+}
+
+// SetDrainFailed records that the drain of the instance has been declared failed, returning true the
+// first time
+func (a *InstanceMonitor) SetDrainFailed() bool {
+
+	if a.drainFailed {
+		return false
+	}
+	a.drainFailed = true
+	return true
+}
+
+// SetMaintenanceScheduled records that the Mesos/Aurora maintenance of the instance has been scheduled,
+// returning true the first time
+func (a *InstanceMonitor) SetMaintenanceScheduled() bool {
+
+	if a.maintenanceScheduled {
+		return false
+	}
+	a.maintenanceScheduled = true
+	return true
+}
+
+// IsDrainFailed is true once the drain of the instance has been declared failed
+func (a *InstanceMonitor) IsDrainFailed() bool {
+	return a.drainFailed
+}
+
 // IsMarkedToBeRemoved is true when the instance has been marked for removal
 func (a *InstanceMonitor) IsMarkedToBeRemoved() bool {
 	return a.tagRemovalTimestamp != 0
@@ -110,14 +489,19 @@ func (a *InstanceMonitor) RefreshLifecycleHook() error {
 
 	// Reset the lifecycle timeout for the instance
 	log.Debugf("Refresh lifecycle hook for instance %s", *a.InstanceID())
-	err := a.ctx.AwsConn.RecordLifecycleActionHeartbeat(
+	err := a.awsConn.RecordLifecycleActionHeartbeat(
 		a.AutoscalingGroupID(), a.InstanceID())
+	a.EmitEvent(events.LifecycleHeartbeat, nil, err)
 	if err != nil {
 		log.Errorf("Unable to record lifecycle action on instance %s", *a.InstanceID())
 		return err
 	}
+	metrics.LifecycleHeartbeats.Inc(a.autoscalingGroupID)
+	if err := a.tagRemovalTimes(); err != nil {
+		log.Warnf("Unable to tag the removal times of instance %s: %s", a.instanceID, err)
+	}
 	// Tag the instance with the new timestamp
-	err = a.TagToBeRemoved()
+	err = a.retagToBeRemoved()
 	if err != nil {
 		log.Warnf("Unable to re-tag the instance after record lifecycle on instance %s", a.InstanceID())
 		return err
@@ -125,14 +509,179 @@ func (a *InstanceMonitor) RefreshLifecycleHook() error {
 	return nil
 }
 
+// EmitEvent emits an event for the instance. If err is not nil, an error event is emitted instead, with
+// the failed event type as its action
+func (a *InstanceMonitor) EmitEvent(eventType string, details map[string]interface{}, err error) {
+
+	event := events.Event{
+		Type:             eventType,
+		AutoscalingGroup: a.autoscalingGroupID,
+		InstanceID:       a.instanceID,
+		IP:               a.ipAddress,
+		Details:          details,
+	}
+
+	if err != nil {
+		if event.Details == nil {
+			event.Details = map[string]interface{}{}
+		}
+		event.Details["action"] = eventType
+		event.Type = events.Error
+		event.Error = err.Error()
+	}
+
+	a.ctx.Emit(event)
+}
+
+// TerminatingWaitSince returns when the instance entered Terminating:Wait, or the zero time if it's not on it
+func (a *InstanceMonitor) TerminatingWaitSince() time.Time {
+	return a.terminatingWaitSince
+}
+
+// SetDeadlineAlerted records that the lifecycle action deadline of the instance has been alerted, returning
+// true the first time
+func (a *InstanceMonitor) SetDeadlineAlerted() bool {
+
+	if a.deadlineAlerted {
+		return false
+	}
+	a.deadlineAlerted = true
+	return true
+}
+
 func (a *InstanceMonitor) setLifecycleState(lifecycleState string) {
+	previousLifecycleState := a.lifecycleState
 	a.lifecycleState = lifecycleState
+	if lifecycleState != LifecycleStateTerminatingWait {
+		a.lifecycleActionToken = ""
+		a.terminatingWaitSince = time.Time{}
+		a.deadlineAlerted = false
+	} else if a.terminatingWaitSince.IsZero() {
+		a.setTerminatingWaitSince(previousLifecycleState == LifecycleStateTerminatingWait)
+	}
+}
+
+// isUnmarkedTermination is true when the instance went to Terminating:Wait without deathnode marking it,
+// because it went unhealthy or it was terminated outside deathnode
+func (a *InstanceMonitor) isUnmarkedTermination() bool {
+	return a.lifecycleState == LifecycleStateTerminatingWait && !a.IsMarkedToBeRemoved()
+}
+
+// setTerminatingWaitSince records when the instance entered Terminating:Wait. For instances found already on
+// it, it's read from their TerminatingWaitSuffix tag or, if they were never heartbeated, their first mark is
+// the closest known time before they entered it
+func (a *InstanceMonitor) setTerminatingWaitSince(foundOnIt bool) {
+
+	a.terminatingWaitSince = a.ctx.Clock.Now()
+	if foundOnIt && a.terminatingWaitTag != 0 {
+		a.terminatingWaitSince = time.Unix(a.terminatingWaitTag, 0)
+	} else if foundOnIt && a.markTimestamp != 0 {
+		a.terminatingWaitSince = time.Unix(a.markTimestamp, 0)
+	}
+}
+
+// tagRemovalTimes stores when the instance was first marked and when it entered Terminating:Wait on their own
+// tags, before its DeathNodeMark tag value is rewritten by the first heartbeat, so both survive restarts
+func (a *InstanceMonitor) tagRemovalTimes() error {
+
+	if a.terminatingWaitTag != 0 || a.terminatingWaitSince.IsZero() {
+		return nil
+	}
+
+	err := a.awsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark+MarkedAtSuffix,
+		fmt.Sprintf("%v", a.markTimestamp), a.instanceID)
+	if err != nil {
+		return err
+	}
+	err = a.awsConn.SetInstanceTag(a.ctx.Conf.DeathNodeMark+TerminatingWaitSuffix,
+		fmt.Sprintf("%v", a.terminatingWaitSince.Unix()), a.instanceID)
+	if err != nil {
+		return err
+	}
+	a.terminatingWaitTag = a.terminatingWaitSince.Unix()
+	return nil
+}
+
+// adoptTermination tags an instance that AWS is terminating without deathnode having marked it, so it's
+// put in maintenance, drained and destroyed as any other marked instance
+func (a *InstanceMonitor) adoptTermination() {
+
+	log.Infof("Instance %s went to %s without being marked (health status %s). Adopting its termination",
+		a.instanceID, LifecycleStateTerminatingWait, a.healthStatus)
+	err := a.TagToBeRemoved()
+	a.EmitEvent(events.TerminationAdopted, map[string]interface{}{"healthStatus": a.healthStatus}, err)
+	if err != nil {
+		log.Errorf("Unable to adopt termination of instance %s: %s", a.instanceID, err)
+		return
+	}
+	a.externalTermination = true
+}
+
+// IsExternalTermination is true for the instances that went to Terminating:Wait without being marked
+func (a *InstanceMonitor) IsExternalTermination() bool {
+	return a.externalTermination
+}
+
+// IsUnhealthy is true when the autoscaling group considers the instance unhealthy
+func (a *InstanceMonitor) IsUnhealthy() bool {
+	return a.healthStatus == HealthStatusUnhealthy
+}
+
+// refreshDescription updates the IP and the removal tags of the instance from a newer EC2 description. Marks
+// set outside of this instance monitor are adopted, while a missing mark is only reported, since the instance
+// may already be unprotected
+func (a *InstanceMonitor) refreshDescription(response *ec2.Instance) {
 
-	if lifecycleState == LifecycleStateTerminatingWait && a.isProtected {
-		// A non-controled instance went to Terminating:Wait, probably because it went unhealthy
-		log.Debugf("setLifecycleState called for instance %s", *a.InstanceID())
-		a.RefreshLifecycleHook()
+	if response.PrivateIpAddress != nil {
+		a.ipAddress = *response.PrivateIpAddress
+	}
+
+	tagRemovalTimestamp, err := getTagRemovalTimestamp(response.Tags, a.ctx.Conf.DeathNodeMark)
+	if err != nil {
+		log.Warnf("Invalid value found for tag %s on instance %s", a.ctx.Conf.DeathNodeMark, a.instanceID)
+		return
+	}
+
+	if tagRemovalTimestamp == 0 {
+		if a.IsMarkedToBeRemoved() {
+			log.Warnf("Tag %s is missing on instance %s, which is marked to be removed",
+				a.ctx.Conf.DeathNodeMark, a.instanceID)
+		}
+		return
+	}
+
+	if !a.IsMarkedToBeRemoved() {
+		log.Infof("Instance %s has been tagged to be removed outside deathnode", a.instanceID)
+		a.markTimestamp = getMarkTimestamp(response.Tags, a.ctx.Conf.DeathNodeMark, tagRemovalTimestamp)
+		a.manuallyMarked = hasTag(response.Tags, a.ctx.Conf.DeathNodeMark+ManualMarkSuffix)
+		a.quarantined = hasTag(response.Tags, a.ctx.Conf.DeathNodeMark+QuarantineMarkSuffix)
+	}
+	if tagRemovalTimestamp > a.tagRemovalTimestamp {
+		a.tagRemovalTimestamp = tagRemovalTimestamp
+	}
+}
+
+func hasTag(tags []*ec2.Tag, key string) bool {
+
+	for _, tag := range tags {
+		if key == *tag.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// getMarkTimestamp returns the MarkedAtSuffix tag value, or tagRemovalTimestamp for the instances marked without it
+func getMarkTimestamp(tags []*ec2.Tag, deathNodeMark string, tagRemovalTimestamp int64) int64 {
+
+	if tagRemovalTimestamp == 0 {
+		return 0
+	}
+	markTimestamp, err := getTagRemovalTimestamp(tags, deathNodeMark+MarkedAtSuffix)
+	if err != nil || markTimestamp == 0 {
+		return tagRemovalTimestamp
 	}
+	return markTimestamp
 }
 
 func getTagRemovalTimestamp(tags []*ec2.Tag, deathNodeMark string) (int64, error) {
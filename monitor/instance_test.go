@@ -3,6 +3,7 @@ package monitor
 import (
 	"github.com/alanbover/deathnode/aws"
 	"github.com/alanbover/deathnode/context"
+	"github.com/alanbover/deathnode/events"
 	"github.com/benbjohnson/clock"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
@@ -24,7 +25,7 @@ func TestNewInstanceMonitor(t *testing.T) {
 			Clock: clock.New(),
 		}
 
-		monitor, _ := newInstanceMonitor(ctx, "autoscalingid", "i-249b35ae", "InService", false)
+		monitor, _ := newInstanceMonitor(ctx, ctx.AwsConn, "autoscalingid", "i-249b35ae", "InService", false)
 
 		Convey("it should not be nil", func() {
 			So(monitor, ShouldNotBeNil)
@@ -69,7 +70,7 @@ func TestInstanceMarkToBeRemoved(t *testing.T) {
 			Clock: clock.New(),
 		}
 
-		monitor, _ := newInstanceMonitor(ctx, "autoscalingid", "i-249b35ae", "InService", false)
+		monitor, _ := newInstanceMonitor(ctx, ctx.AwsConn, "autoscalingid", "i-249b35ae", "InService", false)
 		Convey("and isMarkToBeRemoved is called", func() {
 			So(monitor.IsMarkedToBeRemoved(), ShouldBeTrue)
 		})
@@ -93,7 +94,7 @@ func TestInstanceProtection(t *testing.T) {
 			Clock: clock.New(),
 		}
 
-		monitor, _ := newInstanceMonitor(ctx, "autoscalingid", "i-249b35ae", "InService", true)
+		monitor, _ := newInstanceMonitor(ctx, ctx.AwsConn, "autoscalingid", "i-249b35ae", "InService", true)
 		Convey("instance should have instanceProtection", func() {
 			So(monitor.isProtected, ShouldBeTrue)
 		})
@@ -129,27 +130,75 @@ func TestLifecycleState(t *testing.T) {
 			Clock: clock.New(),
 		}
 
-		monitor, _ := newInstanceMonitor(ctx, "autoscalingid", "i-249b35ae", "InService", true)
+		monitor, _ := newInstanceMonitor(ctx, ctx.AwsConn, "autoscalingid", "i-249b35ae", "InService", true)
 		Convey("and we call SetLifecycleState", func() {
-			Convey("when the instance has instanceProtection enabled", func() {
+			Convey("when the instance was not marked to be removed", func() {
+				recorder := &events.Recorder{}
+				ctx.Events = recorder
+				monitor.healthStatus = HealthStatusUnhealthy
 				monitor.setLifecycleState(LifecycleStateTerminatingWait)
 				Convey("instance should have new LifecycleState value", func() {
 					So(monitor.lifecycleState, ShouldEqual, LifecycleStateTerminatingWait)
 				})
-				Convey("MarkToBeRemoved should have been called", func() {
+				Convey("its termination should be left to be adopted by its autoscaling group", func() {
+					So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+					So(monitor.isUnmarkedTermination(), ShouldBeTrue)
+				})
+				Convey("once adopted, it should be marked as an external termination", func() {
+					monitor.adoptTermination()
 					So(awsConn.Requests["SetInstanceTag"], ShouldNotBeNil)
+					So(monitor.isUnmarkedTermination(), ShouldBeFalse)
+					So(monitor.IsExternalTermination(), ShouldBeTrue)
+					So(monitor.IsUnhealthy(), ShouldBeTrue)
+					So(recorder.OfType(events.TerminationAdopted), ShouldHaveLength, 1)
 				})
 			})
-			Convey("when the instance has instanceProtection disabled", func() {
+			Convey("when the instance was already marked to be removed", func() {
 				monitor.isProtected = false
+				monitor.tagRemovalTimestamp = 1190995200
 				monitor.setLifecycleState(LifecycleStateTerminatingWait)
 				Convey("instance should have new LifecycleState value", func() {
 					So(monitor.lifecycleState, ShouldEqual, LifecycleStateTerminatingWait)
 				})
 				Convey("MarkToBeRemoved should not have been called", func() {
 					So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+					So(monitor.IsExternalTermination(), ShouldBeFalse)
 				})
 			})
 		})
 	})
 }
+
+func TestRemovalTimesAfterRestart(t *testing.T) {
+
+	Convey("When an instance already heartbeated in Terminating:Wait is found", t, func() {
+		awsConn := &aws.ConnectionMock{
+			Records: map[string]*[]string{
+				"DescribeInstanceById": {"node_heartbeated"},
+			},
+		}
+
+		ctx := &context.ApplicationContext{
+			AwsConn: awsConn,
+			Conf: context.ApplicationConf{
+				DeathNodeMark: "DEATH_NODE_MARK",
+			},
+			Clock: clock.New(),
+		}
+
+		monitor, _ := newInstanceMonitor(ctx, ctx.AwsConn, "autoscalingid", "i-249b35ae",
+			LifecycleStateTerminatingWait, false)
+		monitor.setLifecycleState(LifecycleStateTerminatingWait)
+		Convey("its mark timestamp should be read from its own tag, not the refreshed removal tag", func() {
+			So(monitor.TagRemovalTimestamp(), ShouldEqual, 1191002400)
+			So(monitor.MarkTimestamp(), ShouldEqual, 1190995200)
+		})
+		Convey("it should have entered Terminating:Wait at its tag value", func() {
+			So(monitor.TerminatingWaitSince().Unix(), ShouldEqual, 1190998800)
+		})
+		Convey("the removal times should not be tagged again", func() {
+			So(monitor.tagRemovalTimes(), ShouldBeNil)
+			So(awsConn.Requests["SetInstanceTag"], ShouldBeNil)
+		})
+	})
+}
@@ -14,8 +14,10 @@ import (
 
 // MesosMonitor monitors the mesos cluster, creating a cache to reduce the number of calls against it
 type MesosMonitor struct {
-	mesosCache *mesosCache
-	ctx        *context.ApplicationContext
+	mesosCache           *mesosCache
+	protectedFrameworks  []string
+	protectedTasksLabels []string
+	ctx                  *context.ApplicationContext
 }
 
 // MesosCache stores the objects of the mesosApi in a way that is directly accesible
@@ -41,22 +43,51 @@ func NewMesosMonitor(ctx *context.ApplicationContext) *MesosMonitor {
 	}
 }
 
-// Refresh updates the mesos cache
-func (m *MesosMonitor) Refresh() {
+// WithProtection returns a MesosMonitor sharing this monitor's cache, but evaluating the protected
+// conditions against the given protected frameworks and tasks labels
+func (m *MesosMonitor) WithProtection(protectedFrameworks, protectedTasksLabels []string) *MesosMonitor {
 
-	m.updateLeaderURL()
-	m.mesosCache.tasks = m.getTasks()
-	m.mesosCache.frameworks = m.getProtectedFrameworks()
-	m.mesosCache.slaves = m.getSlaves()
+	return &MesosMonitor{
+		mesosCache:           m.mesosCache,
+		protectedFrameworks:  protectedFrameworks,
+		protectedTasksLabels: protectedTasksLabels,
+		ctx:                  m.ctx,
+	}
 }
 
-func (m *MesosMonitor) getProtectedFrameworks() map[string]mesos.Framework {
+// Refresh updates the mesos cache. It returns the first error found, if any, while keeping the
+// data retrieved from the rest of the calls
+func (m *MesosMonitor) Refresh() error {
 
-	protectedFrameworksMap := map[string]mesos.Framework{}
+	var errs []error
+	_, err := m.updateLeaderURL()
+	errs = append(errs, err)
+
+	var tasksErr, frameworksErr, slavesErr error
+	m.mesosCache.tasks, tasksErr = m.getTasks()
+	m.mesosCache.frameworks, frameworksErr = m.getFrameworks()
+	m.mesosCache.slaves, slavesErr = m.getSlaves()
+	errs = append(errs, tasksErr, frameworksErr, slavesErr)
+
+	for _, framework := range m.getProtectedFrameworks() {
+		log.Infof("Found matching protected framework %s with id: %s", framework.Name, framework.ID)
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MesosMonitor) getFrameworks() (map[string]mesos.Framework, error) {
+
+	frameworksMap := map[string]mesos.Framework{}
 	response, err := m.ctx.MesosConn.GetMesosFrameworks()
 	if err != nil {
 		log.WithField("error", err).Warning("Error getting mesos frameworks")
-		return protectedFrameworksMap
+		return frameworksMap, err
 	}
 
 	if len(response.Frameworks) == 0 {
@@ -64,9 +95,17 @@ func (m *MesosMonitor) getProtectedFrameworks() map[string]mesos.Framework {
 	}
 	for _, framework := range response.Frameworks {
 		log.Debugf("Found %s framework %s with id: %s.", genFrameworkActiveString(framework.Active), framework.Name, framework.ID)
-		for _, protectedFramework := range m.ctx.Conf.ProtectedFrameworks {
+		frameworksMap[framework.ID] = framework
+	}
+	return frameworksMap, nil
+}
+
+func (m *MesosMonitor) getProtectedFrameworks() map[string]mesos.Framework {
+
+	protectedFrameworksMap := map[string]mesos.Framework{}
+	for _, framework := range m.mesosCache.frameworks {
+		for _, protectedFramework := range m.getProtectedFrameworkNames() {
 			if protectedFramework == framework.Name {
-				log.Infof("Found matching protected framework %s with id: %s", framework.Name, framework.ID)
 				protectedFrameworksMap[framework.ID] = framework
 			}
 		}
@@ -74,6 +113,22 @@ func (m *MesosMonitor) getProtectedFrameworks() map[string]mesos.Framework {
 	return protectedFrameworksMap
 }
 
+func (m *MesosMonitor) getProtectedFrameworkNames() []string {
+
+	if m.protectedFrameworks != nil {
+		return m.protectedFrameworks
+	}
+	return m.ctx.Conf.ProtectedFrameworks
+}
+
+func (m *MesosMonitor) getProtectedTasksLabels() []string {
+
+	if m.protectedTasksLabels != nil {
+		return m.protectedTasksLabels
+	}
+	return m.ctx.Conf.ProtectedTasksLabels
+}
+
 func genFrameworkActiveString(a bool) string {
 	if a {
 		return "active"
@@ -82,20 +137,20 @@ func genFrameworkActiveString(a bool) string {
 	return "inactive"
 }
 
-func (m *MesosMonitor) getSlaves() map[string]mesos.Slave {
+func (m *MesosMonitor) getSlaves() (map[string]mesos.Slave, error) {
 
 	slavesMap := map[string]mesos.Slave{}
 	response, err := m.ctx.MesosConn.GetMesosAgents()
 	if err != nil {
 		log.Warning(err)
-		return slavesMap
+		return slavesMap, err
 	}
 
 	for _, slave := range response.Slaves {
 		ipAddress := m.getAgentIPAddressFromPID(slave.Pid)
 		slavesMap[ipAddress] = slave
 	}
-	return slavesMap
+	return slavesMap, nil
 }
 
 func (m *MesosMonitor) getAgentIPAddressFromPID(pid string) string {
@@ -107,7 +162,7 @@ func (m *MesosMonitor) getAgentIPAddressFromPID(pid string) string {
 func (m *MesosMonitor) isTaskProtected(task mesos.Task) bool {
 
 	for _, label := range task.Labels {
-		for _, protectedTasksLabel := range m.ctx.Conf.ProtectedTasksLabels {
+		for _, protectedTasksLabel := range m.getProtectedTasksLabels() {
 			if label.Key == protectedTasksLabel && strings.ToUpper(label.Value) == "TRUE" {
 				return true
 			}
@@ -116,32 +171,31 @@ func (m *MesosMonitor) isTaskProtected(task mesos.Task) bool {
 	return false
 }
 
-func (m *MesosMonitor) updateLeaderURL() string {
+func (m *MesosMonitor) updateLeaderURL() (string, error) {
 
 	leaderURL, err := m.ctx.MesosConn.UpdateMesosLeaderURL()
 	if err != nil {
 		log.WithField("error", err).Error("Failed to get Mesos leader URL.")
 	}
 
-	return leaderURL
+	return leaderURL, err
 }
 
-func (m *MesosMonitor) getTasks() map[string][]mesos.Task {
+func (m *MesosMonitor) getTasks() (map[string][]mesos.Task, error) {
 
 	tasksMap := map[string][]mesos.Task{}
 	response, err := m.ctx.MesosConn.GetMesosTasks()
 	if err != nil {
 		log.Warning(err)
-		return tasksMap
+		return tasksMap, err
 	}
 
 	for _, task := range response.Tasks {
 		if task.State == "TASK_RUNNING" {
-			task.IsProtected = m.isTaskProtected(task)
 			tasksMap[task.SlaveID] = append(tasksMap[task.SlaveID], task)
 		}
 	}
-	return tasksMap
+	return tasksMap, nil
 }
 
 // SetMesosAgentsInMaintenance sets a list of mesos agents in Maintenance mode
@@ -149,13 +203,26 @@ func (m *MesosMonitor) SetMesosAgentsInMaintenance(hosts map[string]string) erro
 	return m.ctx.MesosConn.SetHostsInMaintenance(hosts)
 }
 
+// IsAgentActive returns true if an active mesos agent is registered with the ipAddress
+func (m *MesosMonitor) IsAgentActive(ipAddress string) bool {
+
+	slave, ok := m.mesosCache.slaves[ipAddress]
+	return ok && slave.Active
+}
+
 func (m *MesosMonitor) isFromProtectedFramework(task mesos.Task) bool {
 
 	framework, ok := m.mesosCache.frameworks[task.FrameworkID]
-	if ok {
-		log.Debugf("Framework %s is running on node %s, preventing Deathnode from killing it",
-			framework.Name, task.SlaveID)
-		return true
+	if !ok {
+		return false
+	}
+
+	for _, protectedFramework := range m.getProtectedFrameworkNames() {
+		if protectedFramework == framework.Name {
+			log.Debugf("Framework %s is running on node %s, preventing Deathnode from killing it",
+				framework.Name, task.SlaveID)
+			return true
+		}
 	}
 
 	return false
@@ -163,7 +230,7 @@ func (m *MesosMonitor) isFromProtectedFramework(task mesos.Task) bool {
 
 func (m *MesosMonitor) hasProtectedLabel(task mesos.Task) bool {
 
-	if task.IsProtected {
+	if m.isTaskProtected(task) {
 		log.Debugf("Protected task %s is running on node %s, preventing Deathnode from killing it",
 			task.Name, task.SlaveID)
 		return true
@@ -209,3 +276,16 @@ func (m *MesosMonitor) IsProtected(ipAddress string) bool {
 		return m.hasProtectedLabel(task) || m.isFromProtectedFramework(task)
 	})
 }
+
+// GetProtectedTasks returns the names of the running tasks on the mesos agent that prevent it from being removed
+func (m *MesosMonitor) GetProtectedTasks(ipAddress string) []string {
+
+	protectedTasks := []string{}
+	m.agentTaskEvaluation(ipAddress, func(m *MesosMonitor, task mesos.Task) bool {
+		if m.isTaskProtected(task) || m.isFromProtectedFramework(task) {
+			protectedTasks = append(protectedTasks, task.Name)
+		}
+		return false
+	})
+	return protectedTasks
+}
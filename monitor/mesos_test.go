@@ -14,6 +14,7 @@ func TestGetMesosFrameworks(t *testing.T) {
 
 	Convey("When creating a new mesos monitor", t, func() {
 		monitor := createTestMesosMonitor("frameworkName1", "")
+		monitor.Refresh()
 
 		Convey("getProtectedFrameworks should return only the ones that match the protected frameworks", func() {
 			frameworks := monitor.getProtectedFrameworks()